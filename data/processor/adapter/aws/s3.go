@@ -13,7 +13,6 @@ import (
 	"github.com/viant/cloudless/data/processor/registry"
 	"github.com/viant/cloudless/ioutil"
 	"io"
-	"strings"
 	"time"
 )
 
@@ -26,16 +25,20 @@ type S3Event struct {
 func (e S3Event) NewRequest(ctx context.Context, fs afs.Service, cfg *processor.Config) (*processor.Request, error) {
 	URL := fmt.Sprintf("s3://%s/%s", e.Records[0].S3.Bucket.Name, e.Records[0].S3.Object.Key)
 
-	request := &processor.Request{}
-	if strings.HasSuffix(URL, ".parquet") {
-		request.SourceType = processor.Parquet
-	} else if strings.HasSuffix(URL, ".json") || strings.HasSuffix(URL, ".json.gz") {
-		request.SourceType = processor.JSON
-	} else {
-		request.SourceType = processor.CSV
+	request := &processor.Request{
+		SourceType: cfg.SourceTypeOf(URL),
+		RowType:    registry.RowType(cfg.RowTypeName),
 	}
-
-	if request.SourceType == processor.CSV || request.SourceType == processor.JSON {
+	if decoder, ok := processor.LookupDecoder(request.SourceType).(processor.BinaryDecoder); ok && decoder.RandomAccess() {
+		if request.RowType == nil && cfg.RowTypeName != "" { //otherwise row type is taken from processor.RowTyper
+			return nil, fmt.Errorf(" %v type name '%s' not registered", request.SourceType, cfg.RowTypeName)
+		}
+		buffer, err := fs.DownloadWithURL(ctx, URL)
+		if err != nil {
+			return nil, err
+		}
+		request.ReaderAt = bytes.NewReader(buffer)
+	} else {
 		var options = make([]storage.Option, 0)
 		if cfg.ReaderBufferSize > 0 {
 			object, err := fs.Object(ctx, URL)
@@ -50,9 +53,6 @@ func (e S3Event) NewRequest(ctx context.Context, fs afs.Service, cfg *processor.
 			return nil, err
 		}
 		request.ReadCloser = reader
-		if request.SourceType == processor.JSON {
-			request.RowType = registry.RowType(cfg.RowTypeName)
-		}
 		if cfg.ReaderBufferSize == 0 {
 			buf := new(bytes.Buffer)
 			if _, err := io.Copy(buf, reader); err != nil {
@@ -61,15 +61,6 @@ func (e S3Event) NewRequest(ctx context.Context, fs afs.Service, cfg *processor.
 			reader.Close()
 			request.ReadCloser = io.NopCloser(bytes.NewReader(buf.Bytes()))
 		}
-	} else { // Parquet
-		if request.RowType = registry.RowType(cfg.RowTypeName); request.RowType == nil {
-			return nil, fmt.Errorf(" parquet type name '%s' not registered", cfg.RowTypeName)
-		}
-		buffer, err := fs.DownloadWithURL(ctx, URL)
-		if err != nil {
-			return nil, err
-		}
-		request.ReaderAt = bytes.NewReader(buffer)
 	}
 	request.SourceURL = URL
 	request.StartTime = time.Now()
@@ -0,0 +1,442 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/viant/afs"
+	"github.com/viant/toolbox"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//Aggregate functions
+	AggregateSum      = "sum"
+	AggregateCount    = "count"
+	AggregateMin      = "min"
+	AggregateMax      = "max"
+	AggregateDistinct = "distinct" //approximate (HyperLogLog) distinct count
+)
+
+const (
+	defaultAggregateShards = 32
+	hllPrecision           = 12
+)
+
+type aggregationKeyType string
+
+// aggregationKey represents context key of the aggregation state
+const aggregationKey = aggregationKeyType("aggregation")
+
+// aggregatePrefix identifies spilled partial aggregate, Key has to be the first aggregateRow field
+var aggregatePrefix = []byte(`{"AggregateKey":`)
+
+type (
+	// KeyExtractor returns record aggregation key
+	KeyExtractor func(data interface{}) (string, error)
+
+	// ValueExtractor returns record value, nil values are ignored
+	ValueExtractor func(data interface{}) (interface{}, error)
+
+	// Aggregate represents aggregate function definition
+	Aggregate struct {
+		Name  string         //output field name
+		Func  string         //sum, count, min, max or distinct
+		Value ValueExtractor //optional for count
+	}
+
+	// Aggregator represents reusable reduce processor, aggregated rows are written to the Destination in Post
+	Aggregator struct {
+		Key        KeyExtractor
+		Aggregates []*Aggregate
+		Shards     int //number of state map shards, 32 by default
+		fs         afs.Service
+	}
+
+	// aggregateValue represents aggregate function state
+	aggregateValue struct {
+		Count     int64   `json:",omitempty"`
+		Sum       float64 `json:",omitempty"`
+		Min       float64 `json:",omitempty"`
+		Max       float64 `json:",omitempty"`
+		Registers []byte  `json:",omitempty"`
+	}
+
+	// aggregateUpdate represents extracted record values or decoded partial aggregate
+	aggregateUpdate struct {
+		key     string
+		values  []interface{}
+		partial []*aggregateValue
+	}
+
+	// aggregateRow represents partial aggregate spilled to the retry destination
+	aggregateRow struct {
+		Key    string `json:"AggregateKey"`
+		Values []*aggregateValue
+	}
+
+	aggregateShard struct {
+		mux    sync.Mutex
+		values map[string][]*aggregateValue
+	}
+
+	// aggregation represents sharded aggregation state of a processing run
+	aggregation struct {
+		shards []*aggregateShard
+	}
+)
+
+// FieldKey returns key extractor of CSV field index or JSON field name values
+func FieldKey(spec Spec, fields ...Field) KeyExtractor {
+	partition := &Partition{Spec: spec, By: fields}
+	return func(data interface{}) (string, error) {
+		return partition.Key(data), nil
+	}
+}
+
+// FieldValue returns value extractor of CSV field index or JSON field name
+func FieldValue(spec Spec, field Field) ValueExtractor {
+	if strings.ToLower(spec.Format) == "csv" && spec.Delimiter == "" {
+		spec.Delimiter = ","
+	}
+	return func(data interface{}) (interface{}, error) {
+		bs, ok := data.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unsupported record type: %T", data)
+		}
+		value := field.Value(bs, &spec)
+		if text, ok := value.([]byte); ok {
+			value = string(text)
+		}
+		if value == "" {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+// Pre initialises aggregation state
+func (a *Aggregator) Pre(ctx context.Context, reporter Reporter) (context.Context, error) {
+	if a.fs == nil {
+		return nil, fmt.Errorf("aggregator storage service was empty, use NewAggregator")
+	}
+	shards := a.Shards
+	if shards == 0 {
+		shards = defaultAggregateShards
+	}
+	state := &aggregation{shards: make([]*aggregateShard, shards)}
+	for i := range state.shards {
+		state.shards[i] = &aggregateShard{values: map[string][]*aggregateValue{}}
+	}
+	return context.WithValue(ctx, aggregationKey, state), nil
+}
+
+// Process aggregates record, batched lines are extracted before any line is aggregated,
+// so that a batch failing on any line is retried or reported as corrupted without double counting
+func (a *Aggregator) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	state, ok := ctx.Value(aggregationKey).(*aggregation)
+	if !ok {
+		return fmt.Errorf("aggregation state was empty")
+	}
+	bs, ok := data.([]byte)
+	if !ok {
+		update, err := a.extract(data)
+		if err != nil {
+			return err
+		}
+		a.apply(state, update)
+		return nil
+	}
+	var updates []*aggregateUpdate
+	for _, line := range bytes.Split(bs, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var update *aggregateUpdate
+		var err error
+		if bytes.HasPrefix(line, aggregatePrefix) {
+			update, err = a.decodePartial(line)
+		} else {
+			update, err = a.extract(line)
+		}
+		if err != nil {
+			return err
+		}
+		updates = append(updates, update)
+	}
+	for _, update := range updates {
+		a.apply(state, update)
+	}
+	return nil
+}
+
+// extract extracts record key and aggregate values
+func (a *Aggregator) extract(data interface{}) (*aggregateUpdate, error) {
+	key, err := a.Key(data)
+	if err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("failed to extract key: %v, due to %v", data, err))
+	}
+	var values = make([]interface{}, len(a.Aggregates))
+	for i, aggregate := range a.Aggregates {
+		if aggregate.Value == nil {
+			continue
+		}
+		if values[i], err = aggregate.Value(data); err != nil {
+			return nil, NewDataCorruption(fmt.Sprintf("failed to extract %v: %v, due to %v", aggregate.Name, data, err))
+		}
+		if values[i] == nil || aggregate.Func == AggregateDistinct || aggregate.Func == AggregateCount {
+			continue
+		}
+		if values[i], err = toolbox.ToFloat(values[i]); err != nil {
+			return nil, NewDataCorruption(fmt.Sprintf("invalid %v: %v, due to %v", aggregate.Name, data, err))
+		}
+	}
+	return &aggregateUpdate{key: key, values: values}, nil
+}
+
+// decodePartial decodes spilled partial aggregate
+func (a *Aggregator) decodePartial(data []byte) (*aggregateUpdate, error) {
+	row := &aggregateRow{}
+	if err := json.Unmarshal(data, row); err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("failed to decode partial aggregate: %s, due to %v", data, err))
+	}
+	if len(row.Values) != len(a.Aggregates) {
+		return nil, NewDataCorruption(fmt.Sprintf("invalid partial aggregate: %s, expected %v values", data, len(a.Aggregates)))
+	}
+	return &aggregateUpdate{key: row.Key, partial: row.Values}, nil
+}
+
+// apply adds extracted record values or merges partial aggregate
+func (a *Aggregator) apply(state *aggregation, update *aggregateUpdate) {
+	shard := state.shard(update.key)
+	shard.mux.Lock()
+	defer shard.mux.Unlock()
+	aggregates := shard.get(update.key, a.Aggregates)
+	for i, aggregate := range a.Aggregates {
+		if update.partial != nil {
+			aggregates[i].merge(aggregate.Func, update.partial[i])
+			continue
+		}
+		if aggregate.Value != nil && update.values[i] == nil {
+			continue
+		}
+		aggregates[i].add(aggregate.Func, update.values[i])
+	}
+}
+
+// Post writes aggregated rows to the Destination, rows not written before deadline are spilled to the retry destination
+func (a *Aggregator) Post(ctx context.Context, reporter Reporter) error {
+	state, ok := ctx.Value(aggregationKey).(*aggregation)
+	if !ok {
+		return nil
+	}
+	response := reporter.BaseResponse()
+	keys, values := state.rows()
+	if len(keys) == 0 {
+		return nil
+	}
+	if response.Destination == nil || response.Destination.URL == "" {
+		return fmt.Errorf("failed to flush aggregates: destination was empty")
+	}
+	destination := NewWriter(response.Destination.URL, a.fs)
+	retry, _ := ctx.Value(retryKey).(*retryDestination)
+	for i, key := range keys {
+		if retry != nil && retry.writer != nil && time.Now().After(retry.deadline) { //remaining rows are retried as partial aggregates
+			if err := a.spill(ctx, keys[i:], values[i:], retry.writer); err != nil {
+				_ = destination.Close()
+				return err
+			}
+			break
+		}
+		if err := destination.Write(ctx, a.encode(key, values[i])); err != nil {
+			_ = destination.Close()
+			return err
+		}
+	}
+	return destination.Close()
+}
+
+// spill writes partial aggregates to the retry destination
+func (a *Aggregator) spill(ctx context.Context, keys []string, values [][]*aggregateValue, writer *Writer) error {
+	for i, key := range keys {
+		data, err := json.Marshal(&aggregateRow{Key: key, Values: values[i]})
+		if err != nil {
+			return err
+		}
+		if err = writer.writeRecord(ctx, data, &record{data: data, line: i, count: 1}, errNotProcessed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode encodes aggregated row as JSON object, fields follow aggregate definition order
+func (a *Aggregator) encode(key string, values []*aggregateValue) []byte {
+	buffer := bytes.Buffer{}
+	encodedKey, _ := json.Marshal(key)
+	buffer.WriteString(`{"Key":`)
+	buffer.Write(encodedKey)
+	for i, aggregate := range a.Aggregates {
+		name, _ := json.Marshal(aggregate.Name)
+		buffer.WriteByte(',')
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.WriteString(values[i].result(aggregate.Func))
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes()
+}
+
+func (s *aggregation) shard(key string) *aggregateShard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return s.shards[hash.Sum32()%uint32(len(s.shards))]
+}
+
+// rows returns aggregated keys in order with their values
+func (s *aggregation) rows() ([]string, [][]*aggregateValue) {
+	var keys []string
+	var byKey = map[string][]*aggregateValue{}
+	for _, shard := range s.shards {
+		shard.mux.Lock()
+		for key, values := range shard.values {
+			keys = append(keys, key)
+			byKey[key] = values
+		}
+		shard.mux.Unlock()
+	}
+	sort.Strings(keys)
+	var values = make([][]*aggregateValue, len(keys))
+	for i, key := range keys {
+		values[i] = byKey[key]
+	}
+	return keys, values
+}
+
+func (s *aggregateShard) get(key string, aggregates []*Aggregate) []*aggregateValue {
+	values, ok := s.values[key]
+	if !ok {
+		values = make([]*aggregateValue, len(aggregates))
+		for i, aggregate := range aggregates {
+			values[i] = &aggregateValue{}
+			if aggregate.Func == AggregateDistinct {
+				values[i].Registers = make([]byte, 1<<hllPrecision)
+			}
+		}
+		s.values[key] = values
+	}
+	return values
+}
+
+func (v *aggregateValue) add(fn string, value interface{}) {
+	switch fn {
+	case AggregateDistinct:
+		hllAdd(v.Registers, toolbox.AsString(value))
+	case AggregateSum, AggregateMin, AggregateMax:
+		number := value.(float64)
+		if v.Count == 0 || number < v.Min {
+			v.Min = number
+		}
+		if v.Count == 0 || number > v.Max {
+			v.Max = number
+		}
+		v.Sum += number
+	}
+	v.Count++
+}
+
+func (v *aggregateValue) merge(fn string, partial *aggregateValue) {
+	if partial == nil || partial.Count == 0 {
+		return
+	}
+	if fn == AggregateDistinct {
+		for i := range v.Registers {
+			if i < len(partial.Registers) && partial.Registers[i] > v.Registers[i] {
+				v.Registers[i] = partial.Registers[i]
+			}
+		}
+	}
+	if v.Count == 0 || partial.Min < v.Min {
+		v.Min = partial.Min
+	}
+	if v.Count == 0 || partial.Max > v.Max {
+		v.Max = partial.Max
+	}
+	v.Sum += partial.Sum
+	v.Count += partial.Count
+}
+
+// result returns JSON encoded aggregate result
+func (v *aggregateValue) result(fn string) string {
+	switch fn {
+	case AggregateCount:
+		return strconv.FormatInt(v.Count, 10)
+	case AggregateDistinct:
+		return strconv.FormatUint(hllCount(v.Registers), 10)
+	}
+	if v.Count == 0 {
+		return "null"
+	}
+	switch fn {
+	case AggregateMin:
+		return strconv.FormatFloat(v.Min, 'f', -1, 64)
+	case AggregateMax:
+		return strconv.FormatFloat(v.Max, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v.Sum, 'f', -1, 64)
+}
+
+// hllAdd adds value to HyperLogLog registers
+func hllAdd(registers []byte, value string) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(value))
+	sum := mix64(hash.Sum64())
+	index := sum >> (64 - hllPrecision)
+	rank := byte(bits.LeadingZeros64(sum<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > registers[index] {
+		registers[index] = rank
+	}
+}
+
+// hllCount returns HyperLogLog cardinality estimate
+func hllCount(registers []byte) uint64 {
+	m := float64(len(registers))
+	if m == 0 {
+		return 0
+	}
+	sum, zeros := 0.0, 0
+	for _, register := range registers {
+		sum += math.Ldexp(1, -int(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 { //small range correction
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// mix64 finalizes hash bits avalanche
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// NewAggregator creates an aggregating processor
+func NewAggregator(fs afs.Service, key KeyExtractor, aggregates ...*Aggregate) *Aggregator {
+	return &Aggregator{Key: key, Aggregates: aggregates, fs: fs}
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/tapper/config"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAggregator(fs afs.Service) *Aggregator {
+	spec := Spec{Format: "csv"}
+	return NewAggregator(fs, FieldKey(spec, Field{Index: 0}),
+		&Aggregate{Name: "Records", Func: AggregateCount},
+		&Aggregate{Name: "Total", Func: AggregateSum, Value: FieldValue(spec, Field{Index: 1})},
+		&Aggregate{Name: "Min", Func: AggregateMin, Value: FieldValue(spec, Field{Index: 1})},
+		&Aggregate{Name: "Max", Func: AggregateMax, Value: FieldValue(spec, Field{Index: 1})},
+		&Aggregate{Name: "Items", Func: AggregateDistinct, Value: FieldValue(spec, Field{Index: 2})},
+	)
+}
+
+func TestService_Do_Aggregator(t *testing.T) {
+	var useCases = []struct {
+		description string
+		batchSize   int
+		input       string
+		expect      string
+	}{
+		{
+			description: "single records",
+			input:       "u1,10,a\nu2,3,a\nu1,5,b\nu1,7.5,a\nu2,4,c",
+			expect:      `{"Key":"u1","Records":3,"Total":22.5,"Min":5,"Max":10,"Items":2}` + "\n" + `{"Key":"u2","Records":2,"Total":7,"Min":3,"Max":4,"Items":2}`,
+		},
+		{
+			description: "batched records",
+			batchSize:   2,
+			input:       "u1,10,a\nu2,3,a\nu1,5,b\nu1,7.5,a\nu2,4,c",
+			expect:      `{"Key":"u1","Records":3,"Total":22.5,"Min":5,"Max":10,"Items":2}` + "\n" + `{"Key":"u2","Records":2,"Total":7,"Min":3,"Max":4,"Items":2}`,
+		},
+		{
+			description: "missing values",
+			input:       "u1,,a\nu1,2",
+			expect:      `{"Key":"u1","Records":2,"Total":2,"Min":2,"Max":2,"Items":1}`,
+		},
+	}
+	for i, useCase := range useCases {
+		fs := afs.New()
+		destURL := fmt.Sprintf("mem://localhost/aggregate/dest%v.json", i)
+		srv := New(&Config{Concurrency: 3, BatchSize: useCase.batchSize, MaxExecTimeMs: 2000, DestinationURL: destURL},
+			fs, newTestAggregator(fs), NewReporter)
+		reporter := srv.Do(context.Background(), NewRequest(strings.NewReader(useCase.input), nil, "mem://localhost/data/aggregate.csv"))
+		assert.Equal(t, StatusOk, reporter.BaseResponse().Status, useCase.description)
+		data, err := fs.DownloadWithURL(context.Background(), destURL)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		assert.Equal(t, useCase.expect, string(data), useCase.description)
+	}
+}
+
+func TestAggregator_Process_Batch(t *testing.T) {
+	aggregator := newTestAggregator(afs.New())
+	reporter := NewReporter()
+	ctx, err := aggregator.Pre(context.Background(), reporter)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = aggregator.Process(ctx, []byte("u1,10,a\nu1,x,b"), reporter)
+	assert.True(t, isDataCorruptionError(err))
+	assert.Nil(t, aggregator.Process(ctx, []byte("u1,1,a\nu2,2,b"), reporter))
+	keys, values := ctx.Value(aggregationKey).(*aggregation).rows()
+	assert.Equal(t, []string{"u1", "u2"}, keys)
+	assert.EqualValues(t, 1, values[0][0].Count) //lines of the failed batch are not aggregated
+	assert.EqualValues(t, 1, values[0][1].Sum)
+
+	_, err = (&Aggregator{Key: FieldKey(Spec{Format: "csv"}, Field{Index: 0})}).Pre(context.Background(), reporter)
+	assert.NotNil(t, err)
+}
+
+func TestAggregator_Post_Spill(t *testing.T) {
+	fs := afs.New()
+	aggregator := newTestAggregator(fs)
+	retryURL := "mem://localhost/aggregate/spill-retry01.csv"
+	destURL := "mem://localhost/aggregate/spill.json"
+	reporter := NewReporter()
+	reporter.BaseResponse().Destination = &config.Stream{URL: destURL}
+	ctx, err := aggregator.Pre(context.Background(), reporter)
+	assert.Nil(t, err)
+	for _, line := range []string{"u1,10,a", "u2,3,a", "u1,5,b"} {
+		assert.Nil(t, aggregator.Process(ctx, []byte(line), reporter))
+	}
+	retryWriter := NewWriter(retryURL, fs)
+	expired := context.WithValue(ctx, retryKey, &retryDestination{writer: retryWriter, deadline: time.Now().Add(-time.Second)})
+	assert.Nil(t, aggregator.Post(expired, reporter))
+	assert.Nil(t, retryWriter.Close())
+	spilled, err := fs.DownloadWithURL(context.Background(), retryURL)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(strings.Split(string(spilled), "\n")))
+
+	ctx, _ = aggregator.Pre(context.Background(), reporter)
+	assert.Nil(t, aggregator.Process(ctx, spilled, reporter))
+	assert.Nil(t, aggregator.Process(ctx, []byte("u2,1,b"), reporter))
+	assert.Nil(t, aggregator.Post(ctx, reporter))
+	data, err := fs.DownloadWithURL(context.Background(), destURL)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Key":"u1","Records":2,"Total":15,"Min":5,"Max":10,"Items":2}`+"\n"+`{"Key":"u2","Records":2,"Total":4,"Min":1,"Max":3,"Items":2}`, string(data))
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, cardinality := range []int{10, 1000, 100000} {
+		registers := make([]byte, 1<<hllPrecision)
+		for i := 0; i < cardinality; i++ {
+			hllAdd(registers, fmt.Sprintf("item%v", i))
+			hllAdd(registers, fmt.Sprintf("item%v", i))
+		}
+		estimate := float64(hllCount(registers))
+		assert.True(t, math.Abs(estimate-float64(cardinality))/float64(cardinality) < 0.05, fmt.Sprintf("%v: %v", cardinality, estimate))
+	}
+}
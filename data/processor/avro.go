@@ -0,0 +1,434 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/francoispqt/gojay"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+const (
+	avroMagic     = "Obj\x01"
+	avroSyncSize  = 16
+	avroSchemaKey = "avro.schema"
+	avroCodecKey  = "avro.codec"
+)
+
+type (
+	// avroDecoder represents avro object container file decoder, records are decoded as JSON
+	avroDecoder struct{}
+
+	avroReader struct {
+		reader    *bufio.Reader
+		schema    *avroSchema
+		codec     string
+		sync      []byte
+		block     *bytes.Reader
+		remaining int64
+		rowType   reflect.Type
+		corrupted bool //corrupted block header, remaining data is not readable
+	}
+
+	avroSchema struct {
+		Type    string
+		Fields  []*avroField
+		Items   *avroSchema
+		Values  *avroSchema
+		Symbols []string
+		Size    int
+		Union   []*avroSchema
+	}
+
+	avroField struct {
+		Name   string
+		Schema *avroSchema
+	}
+
+	avroByteReader interface {
+		io.Reader
+		io.ByteReader
+	}
+)
+
+// RandomAccess returns false, avro object container is read sequentially
+func (d *avroDecoder) RandomAccess() bool {
+	return false
+}
+
+// NewReader creates avro record reader
+func (d *avroDecoder) NewReader(ctx context.Context, request *Request, config *Config) (RecordReader, error) {
+	if request.ReadCloser == nil {
+		return nil, fmt.Errorf("reader was empty: %v", request.SourceURL)
+	}
+	result := &avroReader{reader: bufio.NewReader(request.ReadCloser), rowType: request.RowType}
+	if err := result.readHeader(); err != nil {
+		return nil, fmt.Errorf("failed to read avro header: %v, due to %w", request.SourceURL, err)
+	}
+	return result, nil
+}
+
+func (r *avroReader) readHeader() error {
+	magic := make([]byte, len(avroMagic))
+	if _, err := io.ReadFull(r.reader, magic); err != nil {
+		return err
+	}
+	if string(magic) != avroMagic {
+		return fmt.Errorf("invalid avro magic: %q", magic)
+	}
+	metadata := map[string][]byte{}
+	for {
+		count, err := readAvroBlockCount(r.reader)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			break
+		}
+		for i := int64(0); i < count; i++ {
+			key, err := readAvroBytes(r.reader)
+			if err != nil {
+				return err
+			}
+			value, err := readAvroBytes(r.reader)
+			if err != nil {
+				return err
+			}
+			metadata[string(key)] = value
+		}
+	}
+	r.sync = make([]byte, avroSyncSize)
+	if _, err := io.ReadFull(r.reader, r.sync); err != nil {
+		return err
+	}
+	r.codec = string(metadata[avroCodecKey])
+	switch r.codec {
+	case "", "null", "deflate":
+	default:
+		return fmt.Errorf("unsupported avro codec: %v", r.codec)
+	}
+	var schema interface{}
+	if err := json.Unmarshal(metadata[avroSchemaKey], &schema); err != nil {
+		return fmt.Errorf("invalid avro schema: %w", err)
+	}
+	var err error
+	r.schema, err = parseAvroSchema(schema, map[string]*avroSchema{})
+	return err
+}
+
+// Read reads avro record as JSON, or decodes it into a row type if specified
+func (r *avroReader) Read() (interface{}, error) {
+	if r.corrupted {
+		return nil, io.EOF
+	}
+	if r.remaining == 0 {
+		if err := r.readBlock(); err != nil {
+			return nil, err
+		}
+	}
+	r.remaining--
+	buffer := new(bytes.Buffer)
+	if err := r.schema.decode(r.block, buffer); err != nil { //block data is read upfront, any decoding failure is block corruption
+		r.remaining = 0 //remaining block records are not readable, the next block is
+		if !isDataCorruptionError(err) {
+			err = NewDataCorruption(fmt.Sprintf("failed to decode avro record, due to %v", err))
+		}
+		return nil, err
+	}
+	data := buffer.Bytes()
+	if r.rowType == nil {
+		return data, nil
+	}
+	rowPtr := reflect.New(r.rowType).Interface()
+	if err := gojay.Unmarshal(data, rowPtr); err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("failed to decode %T: %s, due to %v", rowPtr, data, err))
+	}
+	return rowPtr, nil
+}
+
+func (r *avroReader) readBlock() error {
+	for r.remaining == 0 {
+		count, err := binary.ReadVarint(r.reader)
+		if err != nil {
+			return err //io.EOF once all blocks have been read
+		}
+		size, err := binary.ReadVarint(r.reader)
+		if err != nil {
+			return err
+		}
+		data, err := readAvroN(r.reader, size)
+		if err != nil {
+			r.corrupted = isDataCorruptionError(err) //next block offset is unknown
+			return err
+		}
+		sync := make([]byte, avroSyncSize)
+		if _, err = io.ReadFull(r.reader, sync); err != nil {
+			return err
+		}
+		if !bytes.Equal(sync, r.sync) { //the block is skipped, the next block is read
+			return NewDataCorruption("invalid avro block sync marker")
+		}
+		if r.codec == "deflate" {
+			if data, err = io.ReadAll(flate.NewReader(bytes.NewReader(data))); err != nil {
+				return NewDataCorruption(fmt.Sprintf("failed to inflate avro block, due to %v", err))
+			}
+		}
+		r.block = bytes.NewReader(data)
+		r.remaining = count
+	}
+	return nil
+}
+
+// Close closes reader
+func (r *avroReader) Close() error {
+	return nil
+}
+
+func parseAvroSchema(value interface{}, named map[string]*avroSchema) (*avroSchema, error) {
+	switch actual := value.(type) {
+	case string:
+		if schema, ok := named[actual]; ok {
+			return schema, nil
+		}
+		return &avroSchema{Type: actual}, nil
+	case []interface{}:
+		result := &avroSchema{Type: "union"}
+		for _, item := range actual {
+			schema, err := parseAvroSchema(item, named)
+			if err != nil {
+				return nil, err
+			}
+			result.Union = append(result.Union, schema)
+		}
+		return result, nil
+	case map[string]interface{}:
+		aType, _ := actual["type"].(string)
+		if aType == "" {
+			return parseAvroSchema(actual["type"], named)
+		}
+		result := &avroSchema{Type: aType}
+		if name, ok := actual["name"].(string); ok {
+			named[name] = result
+			if namespace, ok := actual["namespace"].(string); ok && namespace != "" {
+				named[namespace+"."+name] = result
+			}
+		}
+		var err error
+		switch aType {
+		case "record", "error":
+			fields, _ := actual["fields"].([]interface{})
+			for _, item := range fields {
+				field, _ := item.(map[string]interface{})
+				name, _ := field["name"].(string)
+				schema, err := parseAvroSchema(field["type"], named)
+				if err != nil {
+					return nil, err
+				}
+				result.Fields = append(result.Fields, &avroField{Name: name, Schema: schema})
+			}
+		case "enum":
+			symbols, _ := actual["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				result.Symbols = append(result.Symbols, fmt.Sprint(symbol))
+			}
+		case "array":
+			result.Items, err = parseAvroSchema(actual["items"], named)
+		case "map":
+			result.Values, err = parseAvroSchema(actual["values"], named)
+		case "fixed":
+			size, _ := actual["size"].(float64)
+			result.Size = int(size)
+		default:
+			if _, ok := named[aType]; ok {
+				return named[aType], nil
+			}
+		}
+		return result, err
+	}
+	return nil, fmt.Errorf("unsupported avro schema: %v", value)
+}
+
+func (s *avroSchema) decode(reader avroByteReader, buffer *bytes.Buffer) error {
+	switch s.Type {
+	case "null":
+		buffer.WriteString("null")
+	case "boolean":
+		b, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(strconv.FormatBool(b != 0))
+	case "int", "long":
+		v, err := binary.ReadVarint(reader)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(strconv.FormatInt(v, 10))
+	case "float":
+		data := make([]byte, 4)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return err
+		}
+		writeJSONFloat(buffer, float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 32)
+	case "double":
+		data := make([]byte, 8)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return err
+		}
+		writeJSONFloat(buffer, math.Float64frombits(binary.LittleEndian.Uint64(data)), 64)
+	case "bytes", "string":
+		data, err := readAvroBytes(reader)
+		if err != nil {
+			return err
+		}
+		writeJSONString(buffer, string(data))
+	case "fixed":
+		data := make([]byte, s.Size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return err
+		}
+		writeJSONString(buffer, string(data))
+	case "enum":
+		index, err := binary.ReadVarint(reader)
+		if err != nil {
+			return err
+		}
+		if index < 0 || int(index) >= len(s.Symbols) {
+			return NewDataCorruption(fmt.Sprintf("invalid avro enum index: %v", index))
+		}
+		writeJSONString(buffer, s.Symbols[index])
+	case "union":
+		index, err := binary.ReadVarint(reader)
+		if err != nil {
+			return err
+		}
+		if index < 0 || int(index) >= len(s.Union) {
+			return NewDataCorruption(fmt.Sprintf("invalid avro union index: %v", index))
+		}
+		return s.Union[index].decode(reader, buffer)
+	case "record", "error":
+		buffer.WriteByte('{')
+		for i, field := range s.Fields {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeJSONString(buffer, field.Name)
+			buffer.WriteByte(':')
+			if err := field.Schema.decode(reader, buffer); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case "array":
+		buffer.WriteByte('[')
+		i := 0
+		err := readAvroBlocks(reader, func() error {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			i++
+			return s.Items.decode(reader, buffer)
+		})
+		if err != nil {
+			return err
+		}
+		buffer.WriteByte(']')
+	case "map":
+		buffer.WriteByte('{')
+		i := 0
+		err := readAvroBlocks(reader, func() error {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			i++
+			key, err := readAvroBytes(reader)
+			if err != nil {
+				return err
+			}
+			writeJSONString(buffer, string(key))
+			buffer.WriteByte(':')
+			return s.Values.decode(reader, buffer)
+		})
+		if err != nil {
+			return err
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported avro type: %v", s.Type)
+	}
+	return nil
+}
+
+func readAvroBlocks(reader avroByteReader, onItem func() error) error {
+	for {
+		count, err := readAvroBlockCount(reader)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		for i := int64(0); i < count; i++ {
+			if err = onItem(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readAvroBlockCount(reader avroByteReader) (int64, error) {
+	count, err := binary.ReadVarint(reader)
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 { //negative count is followed by block size in bytes
+		count = -count
+		if _, err = binary.ReadVarint(reader); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func readAvroBytes(reader avroByteReader) ([]byte, error) {
+	size, err := binary.ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	return readAvroN(reader, size)
+}
+
+// readAvroN reads size bytes, data is not allocated upfront, so corrupted size does not exhaust memory
+func readAvroN(reader io.Reader, size int64) ([]byte, error) {
+	if size < 0 {
+		return nil, NewDataCorruption(fmt.Sprintf("invalid avro size: %v", size))
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) < size {
+		return nil, NewDataCorruption(fmt.Sprintf("invalid avro size: %v, only %v bytes available", size, len(data)))
+	}
+	return data, nil
+}
+
+func writeJSONString(buffer *bytes.Buffer, value string) {
+	data, _ := json.Marshal(value)
+	buffer.Write(data)
+}
+
+func writeJSONFloat(buffer *bytes.Buffer, value float64, bitSize int) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		buffer.WriteString("null")
+		return
+	}
+	buffer.WriteString(strconv.FormatFloat(value, 'g', -1, bitSize))
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff represents retry delay with exponential backoff
+type Backoff struct {
+	BaseMs     int     //delay of the first retry, backoff is disabled if not set
+	Multiplier float64 //delay multiplier of each subsequent retry, 2 by default
+	MaxMs      int     //optional max retry delay
+}
+
+// Delay returns delay of the retry number
+func (b Backoff) Delay(retry int) time.Duration {
+	if b.BaseMs <= 0 {
+		return 0
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	if retry < 1 {
+		retry = 1
+	}
+	delayMs := float64(b.BaseMs) * math.Pow(multiplier, float64(retry-1))
+	if b.MaxMs > 0 && delayMs > float64(b.MaxMs) {
+		delayMs = float64(b.MaxMs)
+	}
+	return time.Duration(delayMs) * time.Millisecond
+}
+
+// withNotBefore adds not before fragment following the retry fragment
+func withNotBefore(URL string, notBefore time.Time) string {
+	index := strings.LastIndex(URL, RetryFragment)
+	if index == -1 {
+		return URL
+	}
+	index += len(RetryFragment) + 2
+	return URL[:index] + NotBeforeFragment + strconv.FormatInt(notBefore.Unix(), 10) + URL[index:]
+}
+
+// notBeforeOf extracts not before time from URL, zero time is returned if URL has no not before fragment
+// eg: s3://bucket/prefix/filename-retry05-notbefore1700000000.csv would extract 2023-11-14T22:13:20Z
+func notBeforeOf(URL string) time.Time {
+	index := strings.LastIndex(URL, NotBeforeFragment)
+	if index == -1 {
+		return time.Time{}
+	}
+	fragment := URL[index+len(NotBeforeFragment):]
+	end := 0
+	for end < len(fragment) && fragment[end] >= '0' && fragment[end] <= '9' {
+		end++
+	}
+	seconds, err := strconv.ParseInt(fragment[:end], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
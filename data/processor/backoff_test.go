@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	var useCases = []struct {
+		description string
+		backoff     Backoff
+		retry       int
+		expect      time.Duration
+	}{
+		{description: "disabled", backoff: Backoff{}, retry: 1, expect: 0},
+		{description: "first retry", backoff: Backoff{BaseMs: 1000}, retry: 1, expect: time.Second},
+		{description: "default multiplier", backoff: Backoff{BaseMs: 1000}, retry: 3, expect: 4 * time.Second},
+		{description: "custom multiplier", backoff: Backoff{BaseMs: 1000, Multiplier: 3}, retry: 3, expect: 9 * time.Second},
+		{description: "max delay", backoff: Backoff{BaseMs: 1000, MaxMs: 5000}, retry: 5, expect: 5 * time.Second},
+	}
+	for _, useCase := range useCases {
+		assert.Equal(t, useCase.expect, useCase.backoff.Delay(useCase.retry), useCase.description)
+	}
+}
+
+func TestRequest_NotBefore(t *testing.T) {
+	notBefore := time.Unix(1700000000, 0)
+	var useCases = []struct {
+		description string
+		URL         string
+		expect      time.Time
+	}{
+		{description: "no retry", URL: "s3://bucket/data/file.csv", expect: time.Time{}},
+		{description: "retry without delay", URL: "s3://bucket/data/file-retry01.csv", expect: time.Time{}},
+		{description: "delayed retry", URL: withNotBefore("s3://bucket/data/file-retry01.csv", notBefore), expect: notBefore},
+	}
+	for _, useCase := range useCases {
+		request := &Request{SourceURL: useCase.URL}
+		assert.Equal(t, useCase.expect, request.NotBefore(), useCase.description)
+	}
+}
+
+func TestService_Do_Backoff(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	cfg := &Config{Concurrency: 1,
+		MaxExecTimeMs:  2000,
+		DestinationURL: "mem://localhost/dest/backoff-sum.txt",
+		RetryURL:       "mem://localhost/tmp/backoff/retry/",
+		FailedURL:      "mem://localhost/tmp/backoff/failed/",
+		MaxRetries:     3,
+		Backoff:        Backoff{BaseMs: 60000},
+	}
+	srv := New(cfg, fs, &sumProcessor{fs: fs, errorOnNumber: 2, err: errors.New("test error")}, NewReporter)
+	reporter := srv.Do(ctx, NewRequest(strings.NewReader("1\n2"), nil, "mem://localhost/data/backoff/numbers.txt"))
+	retryURL := reporter.BaseResponse().RetryURL
+	assert.True(t, strings.Contains(retryURL, RetryFragment+"01"+NotBeforeFragment), retryURL)
+	retry := &Request{SourceURL: retryURL}
+	assert.True(t, retry.NotBefore().After(time.Now().Add(time.Minute)), retryURL)
+	assert.Equal(t, 1, retry.Retry())
+
+	reporter = srv.Do(ctx, NewRequest(strings.NewReader("2"), nil, retryURL))
+	response := reporter.BaseResponse()
+	assert.Equal(t, StatusDeferred, response.Status)
+	if assert.NotNil(t, response.NotBefore) {
+		assert.Equal(t, retry.NotBefore(), *response.NotBefore)
+	}
+	assert.EqualValues(t, 0, response.Processed)
+
+	dueURL := strings.Replace(retryURL, fmt.Sprint(retry.NotBefore().Unix()), fmt.Sprint(time.Now().Add(-time.Second).Unix()), 1)
+	reporter = srv.Do(ctx, NewRequest(strings.NewReader("1"), nil, dueURL))
+	assert.EqualValues(t, 1, reporter.BaseResponse().Processed)
+	assert.Nil(t, reporter.BaseResponse().NotBefore)
+}
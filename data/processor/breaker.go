@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen represents a record routed to the retry destination while circuit is open, it does not count as a processing attempt
+var errCircuitOpen = errors.New("circuit open")
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker represents circuit breaker config, while circuit is open records are routed to the retry destination without calling Process
+type CircuitBreaker struct {
+	ErrorRate  float64 //retriable error rate opening circuit, circuit breaker is enabled if set
+	WindowSize int     //sliding window size (number of recent Process calls), 20 by default
+	MinCalls   int     //min number of calls in the window to evaluate error rate, WindowSize by default
+	OpenMs     int     //time circuit stays open before half-open probe, 1000 by default
+}
+
+// Enabled returns true if circuit breaker is set
+func (c CircuitBreaker) Enabled() bool {
+	return c.ErrorRate > 0
+}
+
+// circuitBreaker represents sliding window circuit breaker
+type circuitBreaker struct {
+	config   CircuitBreaker
+	state    int
+	outcomes []bool
+	index    int
+	count    int
+	failures int
+	openedAt time.Time
+	probing  bool
+	mux      sync.Mutex
+}
+
+// allow returns true if Process can be called, in half-open state only one probe call is allowed
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < time.Duration(b.config.OpenMs)*time.Millisecond {
+			return false
+		}
+		b.state = circuitHalfOpen
+	case circuitClosed:
+		return true
+	}
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// cancel releases half-open probe of a record that has not been processed
+func (b *circuitBreaker) cancel() {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.state == circuitHalfOpen {
+		b.probing = false
+	}
+}
+
+// record records Process call outcome
+func (b *circuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case circuitHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+			return
+		}
+		b.state = circuitClosed
+		b.index, b.count, b.failures = 0, 0, 0
+	case circuitClosed:
+		if b.count == len(b.outcomes) {
+			if b.outcomes[b.index] {
+				b.failures--
+			}
+		} else {
+			b.count++
+		}
+		b.outcomes[b.index] = failed
+		b.index = (b.index + 1) % len(b.outcomes)
+		if failed {
+			b.failures++
+		}
+		if b.count >= b.config.MinCalls && float64(b.failures)/float64(b.count) >= b.config.ErrorRate {
+			b.open()
+		}
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.state = circuitOpen
+	b.openedAt = time.Now()
+}
+
+func newCircuitBreaker(config CircuitBreaker) *circuitBreaker {
+	if config.WindowSize == 0 {
+		config.WindowSize = 20
+	}
+	if config.MinCalls == 0 || config.MinCalls > config.WindowSize {
+		config.MinCalls = config.WindowSize
+	}
+	if config.OpenMs == 0 {
+		config.OpenMs = 1000
+	}
+	return &circuitBreaker{config: config, outcomes: make([]bool, config.WindowSize)}
+}
+
+// circuitBreaker returns service circuit breaker or nil if not enabled
+func (s *Service) circuitBreaker() *circuitBreaker {
+	if !s.Config.CircuitBreaker.Enabled() {
+		return nil
+	}
+	s.breakerOnce.Do(func() {
+		s.breaker = newCircuitBreaker(s.Config.CircuitBreaker)
+	})
+	return s.breaker
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := newCircuitBreaker(CircuitBreaker{ErrorRate: 0.5, WindowSize: 4, OpenMs: 20})
+	var useCases = []struct {
+		description string
+		failed      bool
+		sleep       time.Duration
+		expectAllow bool
+		expectState int
+	}{
+		{description: "closed success", expectAllow: true, expectState: circuitClosed},
+		{description: "closed failure", failed: true, expectAllow: true, expectState: circuitClosed},
+		{description: "closed success", expectAllow: true, expectState: circuitClosed},
+		{description: "window error rate reached", failed: true, expectAllow: true, expectState: circuitOpen},
+		{description: "open", expectAllow: false, expectState: circuitOpen},
+		{description: "half-open probe failure", sleep: 30 * time.Millisecond, failed: true, expectAllow: true, expectState: circuitOpen},
+		{description: "half-open probe success", sleep: 30 * time.Millisecond, expectAllow: true, expectState: circuitClosed},
+		{description: "closed with reset window", failed: true, expectAllow: true, expectState: circuitClosed},
+	}
+	for _, useCase := range useCases {
+		time.Sleep(useCase.sleep)
+		allowed := breaker.allow()
+		assert.Equal(t, useCase.expectAllow, allowed, useCase.description)
+		if allowed {
+			breaker.record(useCase.failed)
+		}
+		assert.Equal(t, useCase.expectState, breaker.state, useCase.description)
+	}
+}
+
+func TestCircuitBreaker_Cancel(t *testing.T) {
+	breaker := newCircuitBreaker(CircuitBreaker{ErrorRate: 1, WindowSize: 1})
+	breaker.record(true)
+	breaker.openedAt = time.Now().Add(-time.Hour)
+	assert.True(t, breaker.allow())
+	assert.False(t, breaker.allow())
+	breaker.cancel()
+	assert.True(t, breaker.allow())
+}
+
+func TestService_Do_CircuitBreaker(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	cfg := &Config{Concurrency: 1,
+		MaxExecTimeMs:  2000,
+		DestinationURL: "mem://localhost/dest/breaker-sum.txt",
+		RetryURL:       "mem://localhost/tmp/breaker/retry/",
+		MaxRetries:     3,
+		CircuitBreaker: CircuitBreaker{ErrorRate: 0.5, WindowSize: 4, OpenMs: 60000},
+	}
+	srv := New(cfg, fs, &sumProcessor{fs: fs, errorOnNumber: 1, err: errors.New("downstream error")}, NewReporter)
+	input := strings.TrimSpace(strings.Repeat("1\n", 20))
+	reporter := srv.Do(ctx, NewRequest(strings.NewReader(input), nil, "mem://localhost/data/breaker/numbers.txt"))
+	response := reporter.BaseResponse()
+	assert.True(t, response.CircuitTripped)
+	assert.EqualValues(t, 4, response.RetriableErrors)
+	assert.EqualValues(t, 20, response.Skipped)
+	retry, err := fs.DownloadWithURL(ctx, response.RetryURL)
+	if assert.Nil(t, err) {
+		assert.Equal(t, input, string(retry))
+	}
+}
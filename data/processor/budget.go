@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+type budgetKeyType string
+
+// budgetKey represents context key of the request in-flight bytes budget
+const budgetKey = budgetKeyType("budget")
+
+// inFlightBudget represents weighted semaphore bounding bytes of records passed from the loader to the workers
+type inFlightBudget struct {
+	limit    int64 //0 tracks in-flight bytes without blocking
+	inFlight int64
+	peak     int64
+	mux      sync.Mutex
+	released *sync.Cond
+}
+
+// acquire waits until record bytes fit the budget, a record exceeding the whole budget is admitted once nothing else is in flight
+func (b *inFlightBudget) acquire(rec *record) {
+	if b == nil {
+		return
+	}
+	rec.size = rec.weight()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for b.limit > 0 && b.inFlight > 0 && b.inFlight+rec.size > b.limit {
+		b.released.Wait()
+	}
+	b.inFlight += rec.size
+	if b.inFlight > b.peak {
+		b.peak = b.inFlight
+	}
+}
+
+// release returns record bytes to the budget
+func (b *inFlightBudget) release(rec *record) {
+	if b == nil || rec.size == 0 {
+		return
+	}
+	b.mux.Lock()
+	b.inFlight -= rec.size
+	b.mux.Unlock()
+	b.released.Broadcast()
+}
+
+// send passes record to the workers once its bytes fit the budget
+func (b *inFlightBudget) send(stream chan *record, rec *record) {
+	b.acquire(rec)
+	stream <- rec
+}
+
+// peakInFlight returns max in-flight bytes
+func (b *inFlightBudget) peakInFlight() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.peak
+}
+
+// sizeOf returns estimated memory size of decoded row value
+func sizeOf(value reflect.Value) int64 {
+	if !value.IsValid() {
+		return 0
+	}
+	result := int64(value.Type().Size())
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			result += sizeOf(value.Elem())
+		}
+	case reflect.String:
+		result += int64(value.Len())
+	case reflect.Slice, reflect.Array:
+		elem := value.Type().Elem()
+		if value.Kind() == reflect.Slice {
+			result += int64(value.Cap()) * int64(elem.Size())
+		}
+		if elem.Kind() >= reflect.Bool && elem.Kind() <= reflect.Complex128 { //numeric elements are fully counted
+			break
+		}
+		for i := 0; i < value.Len(); i++ {
+			result += sizeOf(value.Index(i)) - int64(value.Index(i).Type().Size()) //element header is already counted
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			result += sizeOf(iter.Key()) + sizeOf(iter.Value())
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			result += sizeOf(value.Field(i)) - int64(value.Field(i).Type().Size()) //field header is already counted
+		}
+	}
+	return result
+}
+
+// budgetOf returns context in-flight bytes budget
+func budgetOf(ctx context.Context) *inFlightBudget {
+	budget, _ := ctx.Value(budgetKey).(*inFlightBudget)
+	return budget
+}
+
+func newInFlightBudget(limitMB int) *inFlightBudget {
+	result := &inFlightBudget{limit: int64(limitMB) * 1024 * 1024}
+	result.released = sync.NewCond(&result.mux)
+	return result
+}
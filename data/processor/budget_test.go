@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInFlightBudget_Acquire(t *testing.T) {
+	budget := newInFlightBudget(1)
+	budget.limit = 100
+	first := &record{data: make([]byte, 60)}
+	budget.acquire(first)
+	acquired := make(chan bool)
+	go func() {
+		budget.acquire(&record{data: make([]byte, 60)})
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		assert.Fail(t, "record exceeding budget should wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	budget.release(first)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		assert.Fail(t, "record should be admitted after release")
+	}
+	assert.EqualValues(t, 60, budget.peakInFlight())
+
+	oversized := &record{data: make([]byte, 300)}
+	budget.release(&record{size: 60})
+	budget.acquire(oversized) //admitted with nothing else in flight
+	assert.EqualValues(t, 300, budget.peakInFlight())
+	var nilBudget *inFlightBudget
+	nilBudget.acquire(first)
+	nilBudget.release(first)
+	assert.EqualValues(t, 0, nilBudget.peakInFlight())
+}
+
+type budgetRow struct {
+	ID     int64
+	Name   string
+	Values []int32
+	Tags   map[string]string
+}
+
+func TestRecord_Weight(t *testing.T) {
+	var useCases = []struct {
+		description string
+		rec         *record
+		min         int64
+		max         int64
+	}{
+		{description: "text", rec: &record{data: []byte("abc")}, min: 3, max: 3},
+		{description: "raw", rec: &record{data: &budgetRow{}, raw: make([]byte, 10)}, min: 10, max: 10},
+		{description: "typed row", rec: &record{data: &budgetRow{Name: strings.Repeat("x", 1000), Values: make([]int32, 100), Tags: map[string]string{"k": "v"}}}, min: 1400, max: 2000},
+		{description: "nil", rec: &record{}, min: 0, max: 0},
+	}
+	for _, useCase := range useCases {
+		actual := useCase.rec.weight()
+		assert.True(t, actual >= useCase.min && actual <= useCase.max, useCase.description)
+	}
+}
+
+type rowCounter struct {
+	rowSize int
+	rows    int32
+}
+
+func (p *rowCounter) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt32(&p.rows, int32(len(data.([]byte))/p.rowSize))
+	return nil
+}
+
+func TestService_Do_MemoryBudget(t *testing.T) {
+	row := strings.Repeat("x", 200*1024)
+	var rows []string
+	for i := 0; i < 20; i++ {
+		rows = append(rows, row)
+	}
+	var useCases = []struct {
+		description string
+		budgetMB    int
+		batchSize   int
+		maxPeak     int64
+	}{
+		{description: "unbounded", maxPeak: 20 * 200 * 1024},
+		{description: "bounded records", budgetMB: 1, maxPeak: 1024 * 1024},
+		{description: "bounded batches", budgetMB: 1, batchSize: 2, maxPeak: 1024 * 1024},
+	}
+	for _, useCase := range useCases {
+		processor := &rowCounter{rowSize: len(row)}
+		srv := New(&Config{
+			Concurrency:     4,
+			MaxExecTimeMs:   5000,
+			MaxRetries:      3,
+			ScannerBufferMB: 1,
+			BatchSize:       useCase.batchSize,
+			MemoryBudgetMB:  useCase.budgetMB,
+		}, afs.New(), processor, NewReporter)
+		response := srv.Do(context.Background(), NewRequest(strings.NewReader(strings.Join(rows, "\n")), nil, "mem://localhost/data/budget.csv")).BaseResponse()
+		assert.EqualValues(t, 20, processor.rows, useCase.description)
+		assert.True(t, response.PeakInFlightBytes > 0, useCase.description)
+		assert.True(t, response.PeakInFlightBytes <= useCase.maxPeak, useCase.description)
+	}
+}
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/viant/afs/file"
+	"sync"
+	"time"
+)
+
+const checkpointExt = ".checkpoint"
+
+// Checkpoint represents processing checkpoint, timed out processing resumes from the first not completed record
+type Checkpoint struct {
+	SourceURL  string
+	Line       int    //number of leading source records that have been completed
+	Offset     int64  `json:",omitempty"` //source byte offset of the first not completed record, if known
+	Loaded     int    //number of source records read before deadline
+	Completed  []byte `json:",omitempty"` //completion bitmap of records following Line
+	Resumes    int    `json:",omitempty"`
+	UpdateTime time.Time
+}
+
+// IsCompleted returns true if source record has been completed
+func (c *Checkpoint) IsCompleted(line int) bool {
+	if line < c.Line {
+		return true
+	}
+	return isBitSet(c.Completed, line-c.Line)
+}
+
+// progress tracks source record completion
+type progress struct {
+	checkpoint *Checkpoint
+	base       int
+	completed  []byte
+	offsets    map[int]int64
+	loaded     int
+	truncated  bool
+	mux        sync.Mutex
+}
+
+func (p *progress) resumed() *Checkpoint {
+	if p == nil {
+		return nil
+	}
+	return p.checkpoint
+}
+
+func (p *progress) read(line int, offset int64) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if line >= p.loaded {
+		p.loaded = line + 1
+	}
+	if offset >= 0 && !p.isSet(line) {
+		p.offsets[line] = offset
+	}
+}
+
+func (p *progress) isCompleted(line int) bool {
+	if p == nil {
+		return false
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.isSet(line)
+}
+
+func (p *progress) isSet(line int) bool {
+	if line < p.base {
+		return true
+	}
+	return isBitSet(p.completed, line-p.base)
+}
+
+func (p *progress) complete(line, count int) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for i := line; i < line+count; i++ {
+		if i < p.base {
+			continue
+		}
+		p.completed = setBit(p.completed, i-p.base)
+		delete(p.offsets, i)
+	}
+}
+
+// truncate marks records starting from line as not loaded
+func (p *progress) truncate(line int) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.truncated = true
+	p.loaded = line
+}
+
+// next returns checkpoint or nil if all source records have been completed
+func (p *progress) next(sourceURL string) *Checkpoint {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	line := p.base
+	for line < p.loaded && p.isSet(line) {
+		line++
+	}
+	if line >= p.loaded && !p.truncated {
+		return nil
+	}
+	result := &Checkpoint{SourceURL: sourceURL, Line: line, Loaded: p.loaded, Offset: p.offsets[line], UpdateTime: time.Now()}
+	if p.checkpoint != nil {
+		result.Resumes = p.checkpoint.Resumes + 1
+	}
+	for i := line; i < p.loaded; i++ {
+		if p.isSet(i) {
+			result.Completed = setBit(result.Completed, i-line)
+		}
+	}
+	return result
+}
+
+func newProgress(checkpoint *Checkpoint) *progress {
+	result := &progress{offsets: map[int]int64{}, checkpoint: checkpoint}
+	if checkpoint != nil {
+		result.base = checkpoint.Line
+		result.completed = append(result.completed, checkpoint.Completed...)
+	}
+	return result
+}
+
+func isBitSet(bitmap []byte, index int) bool {
+	if index/8 >= len(bitmap) {
+		return false
+	}
+	return bitmap[index/8]&(1<<(index%8)) != 0
+}
+
+func setBit(bitmap []byte, index int) []byte {
+	for index/8 >= len(bitmap) {
+		bitmap = append(bitmap, 0)
+	}
+	bitmap[index/8] |= 1 << (index % 8)
+	return bitmap
+}
+
+func (s *Service) checkpointURL(request *Request) string {
+	return request.TransformSourceURL(s.Config.CheckpointURL) + checkpointExt
+}
+
+// loadProgress loads prior checkpoint for the request source
+func (s *Service) loadProgress(ctx context.Context, request *Request) (*progress, error) {
+	if s.Config.CheckpointURL == "" {
+		return nil, nil
+	}
+	URL := s.checkpointURL(request)
+	if ok, _ := s.fs.Exists(ctx, URL); !ok {
+		return newProgress(nil), nil
+	}
+	data, err := s.fs.DownloadWithURL(ctx, URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %v, due to %w", URL, err)
+	}
+	checkpoint := &Checkpoint{}
+	if err = json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %v, due to %w", URL, err)
+	}
+	if checkpoint.SourceURL != request.SourceURL {
+		return newProgress(nil), nil
+	}
+	return newProgress(checkpoint), nil
+}
+
+// saveProgress persists checkpoint if some source records have not been completed, otherwise removes prior checkpoint
+func (s *Service) saveProgress(ctx context.Context, request *Request, progress *progress, response *Response) error {
+	if progress == nil {
+		return nil
+	}
+	URL := s.checkpointURL(request)
+	checkpoint := progress.next(request.SourceURL)
+	if checkpoint == nil {
+		if progress.checkpoint != nil {
+			return s.fs.Delete(ctx, URL)
+		}
+		return nil
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err = s.fs.Upload(ctx, URL, file.DefaultFileOsMode, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v, due to %w", URL, err)
+	}
+	response.CheckpointURL = URL
+	return nil
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/async/mbus"
+	"github.com/viant/cloudless/async/mbus/mem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress_Next(t *testing.T) {
+	var useCases = []struct {
+		description string
+		checkpoint  *Checkpoint
+		loaded      int
+		completed   []int
+		truncate    int
+		expectNil   bool
+		expectLine  int
+		expectDone  []int
+	}{
+		{
+			description: "all completed",
+			loaded:      4,
+			completed:   []int{0, 1, 2, 3},
+			truncate:    -1,
+			expectNil:   true,
+		},
+		{
+			description: "gap in completion",
+			loaded:      5,
+			completed:   []int{0, 1, 3},
+			truncate:    -1,
+			expectLine:  2,
+			expectDone:  []int{3},
+		},
+		{
+			description: "truncated load",
+			loaded:      5,
+			completed:   []int{0, 1, 2},
+			truncate:    3,
+			expectLine:  3,
+		},
+		{
+			description: "resumed checkpoint",
+			checkpoint:  &Checkpoint{Line: 2, Completed: setBit(nil, 1)},
+			loaded:      5,
+			completed:   []int{2},
+			truncate:    -1,
+			expectLine:  4,
+		},
+	}
+	for _, useCase := range useCases {
+		progress := newProgress(useCase.checkpoint)
+		for i := 0; i < useCase.loaded; i++ {
+			progress.read(i, int64(i*2))
+		}
+		for _, line := range useCase.completed {
+			progress.complete(line, 1)
+		}
+		if useCase.truncate >= 0 {
+			progress.truncate(useCase.truncate)
+		}
+		actual := progress.next("mem://localhost/data/input.txt")
+		if useCase.expectNil {
+			assert.Nil(t, actual, useCase.description)
+			continue
+		}
+		if !assert.NotNil(t, actual, useCase.description) {
+			continue
+		}
+		assert.Equal(t, useCase.expectLine, actual.Line, useCase.description)
+		assert.EqualValues(t, useCase.expectLine*2, actual.Offset, useCase.description)
+		for _, line := range useCase.expectDone {
+			assert.True(t, actual.IsCompleted(line), useCase.description)
+		}
+		assert.False(t, actual.IsCompleted(useCase.expectLine), useCase.description)
+	}
+}
+
+func TestService_Do_Checkpoint(t *testing.T) {
+	fs := afs.New()
+	input := "1\n2\n3\n4\n5\n6\n7\n8\n9\n0"
+	cfg := &Config{Concurrency: 1,
+		DestinationURL:      "mem://localhost/dest/checkpoint-sum.txt",
+		DeadlineReductionMs: 500,
+		MaxExecTimeMs:       1500,
+		RetryURL:            "mem://localhost/tmp/checkpoint/retry/",
+		CheckpointURL:       "mem://localhost/tmp/checkpoint/",
+		Completion:          &Completion{Dest: &mbus.Resource{Name: fmt.Sprintf("completionCheckpoint%v", time.Now().UnixNano()), Vendor: "mem", Type: mbus.ResourceTypeQueue}},
+	}
+	queue := mem.Singleton().Queue(cfg.Completion.Dest)
+	srv := New(cfg, fs, &sumProcessor{fs: fs, sleepOnNumber: 8, sleepTime: 2 * time.Second}, NewReporter)
+	reporter := srv.Do(context.Background(), NewRequest(strings.NewReader(input), nil, "mem://localhost/data/checkpoint/numbers.txt"))
+	response := reporter.BaseResponse()
+	if !assert.NotEmpty(t, response.CheckpointURL) {
+		return
+	}
+	ok, _ := fs.Exists(context.Background(), response.CheckpointURL)
+	assert.True(t, ok)
+	assert.Equal(t, 0, len(queue)) //completion is published once the source is done
+
+	srv = New(cfg, fs, &sumProcessor{fs: fs}, NewReporter)
+	reporter = srv.Do(context.Background(), NewRequest(strings.NewReader(input), nil, "mem://localhost/data/checkpoint/numbers.txt"))
+	response = reporter.BaseResponse()
+	assert.Empty(t, response.CheckpointURL)
+	assert.True(t, response.CheckpointSkipped > 0)
+	assert.EqualValues(t, 10, int(response.Processed)+int(response.CheckpointSkipped))
+	assert.Equal(t, 1, len(queue))
+	ok, _ = fs.Exists(context.Background(), srv.checkpointURL(&Request{SourceURL: "mem://localhost/data/checkpoint/numbers.txt"}))
+	assert.False(t, ok)
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/viant/cloudless/data/processor/cli"
+	"os"
+)
+
+// main runs built-in processors, to run your processor register it with processor.RegisterProcessor in your own main package
+func main() {
+	cli.Run(os.Args[1:])
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	"github.com/viant/afs"
+	"github.com/viant/afs/url"
+	"github.com/viant/cloudless/data/processor"
+	"github.com/viant/cloudless/data/processor/registry"
+	tconfig "github.com/viant/tapper/config"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Noop represents a processor accepting every record, used to inspect source decoding, validation and routing
+type Noop struct{}
+
+// Process accepts the record
+func (n *Noop) Process(ctx context.Context, data interface{}, reporter processor.Reporter) error {
+	return nil
+}
+
+// Run runs registered processor over the sources and prints every response as JSON
+func Run(args []string) {
+	options := &Options{}
+	remaining, err := flags.ParseArgs(options, args)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	options.SourceURLs = append(options.SourceURLs, remaining...)
+	if err = run(context.Background(), afs.New(), options, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run(ctx context.Context, fs afs.Service, options *Options, writer io.Writer) error {
+	if options.List {
+		_, err := fmt.Fprintln(writer, strings.Join(processor.ProcessorNames(), "\n"))
+		return err
+	}
+	aProcessor := processor.LookupProcessor(options.Processor)
+	if aProcessor == nil {
+		return fmt.Errorf("unknown processor: '%v', registered: %v", options.Processor, processor.ProcessorNames())
+	}
+	if len(options.SourceURLs) == 0 {
+		return fmt.Errorf("source URL was empty")
+	}
+	config, err := loadConfig(ctx, fs, options)
+	if err != nil {
+		return err
+	}
+	service := processor.New(config, fs, aProcessor, processor.NewReporter)
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	for _, URL := range options.SourceURLs {
+		URL = location(URL)
+		reader, err := fs.OpenURL(ctx, URL)
+		if err != nil {
+			return fmt.Errorf("failed to open source: %v, due to %w", URL, err)
+		}
+		request := processor.NewRequest(reader, nil, URL)
+		request.SourceType = config.SourceTypeOf(URL)
+		request.RowType = registry.RowType(config.RowTypeName)
+		reporter := service.Do(ctx, request)
+		if err = encoder.Encode(reporter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadConfig loads YAML or JSON processor config
+func LoadConfig(ctx context.Context, fs afs.Service, URL string) (*processor.Config, error) {
+	config := &processor.Config{}
+	data, err := fs.DownloadWithURL(ctx, location(URL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v, due to %w", URL, err)
+	}
+	if ext := strings.ToLower(filepath.Ext(URL)); ext == ".yaml" || ext == ".yml" {
+		var any interface{}
+		if err = yaml.Unmarshal(data, &any); err != nil {
+			return nil, fmt.Errorf("invalid config: %v, due to %w", URL, err)
+		}
+		if data, err = json.Marshal(any); err != nil {
+			return nil, err
+		}
+	}
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config: %v, due to %w", URL, err)
+	}
+	return config, nil
+}
+
+// loadConfig loads config, every side effect location (retry, failed, corruption, destination, routes, pass through and shadow report)
+// is redirected to the output folder (new temp folder by default), completion events and checkpoints are disabled
+func loadConfig(ctx context.Context, fs afs.Service, options *Options) (*processor.Config, error) {
+	config := &processor.Config{}
+	if options.ConfigURL != "" {
+		var err error
+		if config, err = LoadConfig(ctx, fs, options.ConfigURL); err != nil {
+			return nil, err
+		}
+	}
+	if options.SourceType != "" {
+		config.SourceType = options.SourceType
+	}
+	config.OnDone = "" //sources are never moved or deleted
+	config.OnMirrorURL = ""
+	config.Completion = nil
+	config.CheckpointURL = ""
+	outputURL := options.OutputURL
+	if outputURL == "" {
+		var err error
+		if outputURL, err = os.MkdirTemp("", "processor"); err != nil {
+			return nil, err
+		}
+	}
+	outputURL = location(outputURL)
+	config.RetryURL = url.Join(outputURL, "retry")
+	config.FailedURL = url.Join(outputURL, "failed")
+	config.CorruptionURL = url.Join(outputURL, "corruption")
+	if config.DestinationURL != "" {
+		config.DestinationURL = redirect(outputURL, "destination", config.DestinationURL)
+	}
+	if config.Destination != nil {
+		config.Destination = redirectStream(outputURL, "destination", config.Destination)
+	}
+	if config.Routing != nil {
+		routing := *config.Routing
+		routing.Routes = make([]*processor.Route, len(config.Routing.Routes))
+		for i, route := range config.Routing.Routes {
+			routing.Routes[i] = &processor.Route{Name: route.Name, Stream: *redirectStream(outputURL, path.Join("routes", route.Name), &route.Stream)}
+		}
+		config.Routing = &routing
+	}
+	if config.Sampling != nil {
+		sampling := *config.Sampling
+		sampling.PassThroughURL = url.Join(outputURL, "passthrough")
+		config.Sampling = &sampling
+	}
+	if config.Shadow != nil {
+		shadow := *config.Shadow
+		shadow.ReportURL = url.Join(outputURL, "shadow")
+		config.Shadow = &shadow
+	}
+	return config, config.Init(ctx, fs)
+}
+
+// redirect returns output folder location with the URL file name (macros are kept)
+func redirect(outputURL, folder, URL string) string {
+	return url.Join(outputURL, folder, path.Base(URL))
+}
+
+// redirectStream returns stream copy with URL and rotation URL redirected to the output folder
+func redirectStream(outputURL, folder string, stream *tconfig.Stream) *tconfig.Stream {
+	result := *stream
+	if result.URL != "" {
+		result.URL = redirect(outputURL, folder, result.URL)
+	}
+	if stream.Rotation != nil {
+		rotation := *stream.Rotation
+		if rotation.URL != "" {
+			rotation.URL = redirect(outputURL, folder, rotation.URL)
+		}
+		result.Rotation = &rotation
+	}
+	return &result
+}
+
+// location returns absolute path for relative local location
+func location(URL string) string {
+	if url.Scheme(URL, "") != "" {
+		return URL
+	}
+	if absolute, err := filepath.Abs(URL); err == nil {
+		return absolute
+	}
+	return URL
+}
+
+func init() {
+	processor.RegisterProcessor("noop", &Noop{})
+}
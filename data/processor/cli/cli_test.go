@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/data/processor"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type cliTestProcessor struct{}
+
+func (p *cliTestProcessor) Process(ctx context.Context, data interface{}, reporter processor.Reporter) error {
+	switch string(data.([]byte)) {
+	case "retry":
+		return errors.New("test error")
+	case "corrupted":
+		return processor.NewDataCorruption("test corruption")
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	processor.RegisterProcessor("cliTest", &cliTestProcessor{})
+	baseDir := t.TempDir()
+	sourceURL := path.Join(baseDir, "data.csv")
+	configURL := path.Join(baseDir, "config.yaml")
+	outputURL := path.Join(baseDir, "output")
+	assert.Nil(t, os.WriteFile(sourceURL, []byte("1\nretry\n2\ncorrupted"), 0644))
+	assert.Nil(t, os.WriteFile(configURL, []byte("Concurrency: 1\nMaxRetries: 3\nMaxExecTimeMs: 2000\nOnDone: delete\n"), 0644))
+
+	var useCases = []struct {
+		description  string
+		options      *Options
+		expect       map[string]interface{}
+		expectOutput map[string]string
+		hasError     bool
+	}{
+		{
+			description: "unknown processor",
+			options:     &Options{Processor: "unknown", SourceURLs: []string{sourceURL}},
+			hasError:    true,
+		},
+		{
+			description: "missing source",
+			options:     &Options{Processor: "cliTest"},
+			hasError:    true,
+		},
+		{
+			description: "noop processor",
+			options:     &Options{Processor: "noop", SourceURLs: []string{sourceURL}},
+			expect:      map[string]interface{}{"Status": "ok", "Processed": 4.0, "Loaded": 4.0},
+		},
+		{
+			description: "retry and corruption outputs",
+			options:     &Options{Processor: "cliTest", ConfigURL: configURL, OutputURL: outputURL, SourceURLs: []string{sourceURL}},
+			expect:      map[string]interface{}{"Processed": 2.0, "CorruptionErrors": 1.0, "RetriableErrors": 1.0},
+			expectOutput: map[string]string{
+				"retry":      "retry",
+				"corruption": "corrupted",
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		writer := new(bytes.Buffer)
+		err := run(context.Background(), afs.New(), useCase.options, writer)
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		response := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(writer.Bytes(), &response), useCase.description)
+		for key, value := range useCase.expect {
+			assert.EqualValues(t, value, response[key], useCase.description+" "+key)
+		}
+		for folder, expect := range useCase.expectOutput {
+			files, _ := filepath.Glob(path.Join(outputURL, folder, baseDir, "*"))
+			if assert.Equal(t, 1, len(files), useCase.description+" "+folder) {
+				data, _ := os.ReadFile(files[0])
+				assert.Equal(t, expect, strings.TrimSpace(string(data)), useCase.description+" "+folder)
+			}
+		}
+	}
+	_, err := os.Stat(sourceURL)
+	assert.Nil(t, err, "source should not be deleted")
+}
+
+func TestLoadConfig(t *testing.T) {
+	baseDir := t.TempDir()
+	configURL := path.Join(baseDir, "config.yaml")
+	assert.Nil(t, os.WriteFile(configURL, []byte(`RetryURL: s3://bucket/trigger
+FailedURL: s3://bucket/failed
+CorruptionURL: s3://bucket/corruption
+DestinationURL: s3://bucket/dest/$UUID.json
+CheckpointURL: s3://bucket/checkpoint
+Completion:
+  Dest:
+    Name: done
+    Vendor: aws
+Sampling:
+  Rate: 0.5
+  PassThroughURL: s3://bucket/legacy
+Shadow:
+  ReportURL: s3://bucket/shadow
+Routing:
+  Routes:
+    - Name: us
+      URL: s3://bucket/routes/us.json
+`), 0644))
+	var useCases = []struct {
+		description string
+		outputURL   string
+	}{
+		{description: "output folder", outputURL: path.Join(baseDir, "output")},
+		{description: "default temp folder"},
+	}
+	for _, useCase := range useCases {
+		config, err := loadConfig(context.Background(), afs.New(), &Options{ConfigURL: configURL, OutputURL: useCase.outputURL})
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		assert.Nil(t, config.Completion, useCase.description)
+		assert.Empty(t, config.CheckpointURL, useCase.description)
+		outputURL := useCase.outputURL
+		if outputURL == "" {
+			outputURL = path.Dir(config.RetryURL)
+			assert.True(t, strings.HasPrefix(outputURL, os.TempDir()), useCase.description)
+			defer func() { _ = os.RemoveAll(outputURL) }()
+		}
+		for _, URL := range []string{config.RetryURL, config.FailedURL, config.CorruptionURL, config.DestinationURL, config.Sampling.PassThroughURL, config.Shadow.ReportURL, config.Routing.Routes[0].URL} {
+			assert.True(t, strings.HasPrefix(URL, outputURL), useCase.description+" "+URL)
+		}
+		assert.Equal(t, path.Join(outputURL, "destination", "$UUID.json"), config.DestinationURL, useCase.description)
+	}
+}
@@ -0,0 +1,11 @@
+package cli
+
+// Options represents command line options
+type Options struct {
+	ConfigURL  string   `short:"c" long:"config" description:"processor config URL (YAML or JSON)"`
+	Processor  string   `short:"p" long:"processor" description:"registered processor name"`
+	SourceURLs []string `short:"s" long:"src" description:"source URL (local path or afs URL), remaining arguments are also used as source URLs"`
+	SourceType string   `short:"t" long:"type" description:"optional source type (registered decoder name)"`
+	OutputURL  string   `short:"o" long:"output" description:"optional local folder for retry, failed, corruption, destination and other outputs, new temp folder by default"`
+	List       bool     `short:"l" long:"list" description:"list registered processors"`
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/viant/cloudless/async/mbus"
+	"text/template"
+	"time"
+)
+
+// Completion represents completion event published to async/mbus once the source is processed
+type Completion struct {
+	Dest     *mbus.Resource //topic or queue, the message is pushed with the service registered for Dest.Vendor
+	Template string         //optional text/template of the message body executed with the event, JSON event by default
+	template *template.Template
+}
+
+// Init parses completion template
+func (c *Completion) Init() error {
+	if c.Template == "" {
+		return nil
+	}
+	var err error
+	if c.template, err = template.New("completion").Parse(c.Template); err != nil {
+		return fmt.Errorf("invalid completion template: %w", err)
+	}
+	return nil
+}
+
+// completionContext returns completion publishing context, it is not cancelled with the request context,
+// but it is bounded by the request deadline including the deadline reduction reserved to complete the request
+func (s *Service) completionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := s.Config.Deadline(ctx).Add(time.Duration(s.Config.DeadlineReductionMs) * time.Millisecond)
+	return context.WithDeadline(context.WithoutCancel(ctx), deadline)
+}
+
+// publishCompletion publishes completion event with the response fields and the URLs actually written:
+// DestinationURLs (destination and routes), RetryURL, FailedURL and CorruptionURL.
+// Deferred sources and sources kept to resume from the checkpoint are not reported.
+func (s *Service) publishCompletion(ctx context.Context, reporter Reporter) {
+	completion := s.Config.Completion
+	if completion == nil || completion.Dest == nil {
+		return
+	}
+	response := reporter.BaseResponse()
+	if response.CheckpointURL != "" || response.Status == StatusDeferred {
+		return
+	}
+	event, err := s.completionEvent(reporter)
+	if err != nil {
+		response.LogError(fmt.Errorf("failed to create completion event: %w", err))
+		return
+	}
+	message := &mbus.Message{Subject: response.SourceURL, Data: event}
+	message.AddAttribute("SourceURL", response.SourceURL)
+	message.AddAttribute("Status", event["Status"])
+	if completion.Template != "" {
+		if completion.template == nil {
+			response.LogError(fmt.Errorf("completion template was not initialised, use Config.Init"))
+			return
+		}
+		body := new(bytes.Buffer)
+		if err = completion.template.Execute(body, event); err != nil {
+			response.LogError(fmt.Errorf("failed to execute completion template: %w", err))
+			return
+		}
+		message.Data = body.String()
+	}
+	service := mbus.Lookup(completion.Dest.Vendor)
+	if service == nil {
+		response.LogError(fmt.Errorf("unsupported message vendor: %v", completion.Dest.Vendor))
+		return
+	}
+	confirmation, err := service.Push(ctx, completion.Dest, message)
+	if err != nil {
+		response.LogError(fmt.Errorf("failed to publish completion event of %v, due to %w", response.SourceURL, err))
+		return
+	}
+	if confirmation != nil {
+		response.CompletionID = confirmation.MessageID
+	}
+}
+
+// completionEvent returns response fields with the written destination, retry, failed and corruption URLs
+func (s *Service) completionEvent(reporter Reporter) (map[string]interface{}, error) {
+	data, err := json.Marshal(reporter)
+	if err != nil {
+		return nil, err
+	}
+	event := map[string]interface{}{}
+	if err = json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	response := reporter.BaseResponse()
+	var destinationURLs []string
+	if response.Destination != nil && response.Destination.URL != "" && response.Processed > 0 { //destination is written by the Processor
+		destinationURLs = append(destinationURLs, response.Destination.URL)
+	}
+	if response.Routing != nil {
+		for _, route := range response.Routing.Routes {
+			if response.Routed[route.Name] > 0 {
+				destinationURLs = append(destinationURLs, route.URL)
+			}
+		}
+	}
+	event["DestinationURLs"] = destinationURLs
+	for key, URL := range map[string]string{"RetryURL": response.RetryURL, "FailedURL": response.FailedURL, "CorruptionURL": response.CorruptionURL} {
+		if URL != "" && response.written[URL] {
+			event[key] = URL
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/async/mbus"
+	"github.com/viant/cloudless/async/mbus/mem"
+	"strings"
+	"testing"
+	"time"
+)
+
+type destinationWriter struct {
+	fs afs.Service
+}
+
+func (p *destinationWriter) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	if string(data.([]byte)) == "bad" {
+		return NewDataCorruption("bad record")
+	}
+	return nil
+}
+
+func (p *destinationWriter) Post(ctx context.Context, reporter Reporter) error {
+	return p.fs.Upload(ctx, reporter.BaseResponse().Destination.URL, 0644, strings.NewReader("done"))
+}
+
+func TestService_Do_Completion(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	var useCases = []struct {
+		description string
+		completion  *Completion
+		expect      func(t *testing.T, payload []byte)
+		hasError    bool
+	}{
+		{
+			description: "JSON event",
+			completion:  &Completion{Dest: &mbus.Resource{Name: "completionJSON", Vendor: "mem", Type: mbus.ResourceTypeQueue}},
+			expect: func(t *testing.T, payload []byte) {
+				event := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(payload, &event))
+				assert.Equal(t, "mem://localhost/data/completion.csv", event["SourceURL"])
+				assert.Equal(t, "ok|corrupted", event["Status"])
+				assert.EqualValues(t, 2, event["Processed"])
+				assert.Equal(t, []interface{}{"mem://localhost/completion/dest/completion.csv"}, event["DestinationURLs"])
+				assert.Equal(t, "mem://localhost/completion/corruption/data/completion.csv", event["CorruptionURL"])
+				assert.Nil(t, event["RetryURL"]) //nothing was written to the retry destination
+			},
+		},
+		{
+			description: "template",
+			completion:  &Completion{Dest: &mbus.Resource{Name: "completionTemplate", Vendor: "mem", Type: mbus.ResourceTypeQueue}, Template: `{{.SourceURL}}:{{.Status}}:{{.Processed}}`},
+			expect: func(t *testing.T, payload []byte) {
+				assert.Equal(t, "mem://localhost/data/completion.csv:ok|corrupted:2", string(payload))
+			},
+		},
+		{
+			description: "unsupported vendor",
+			completion:  &Completion{Dest: &mbus.Resource{Name: "completion", Vendor: "xyz", Type: mbus.ResourceTypeQueue}},
+			hasError:    true,
+		},
+	}
+	for _, useCase := range useCases {
+		config := &Config{
+			MaxExecTimeMs:  2000,
+			MaxRetries:     3,
+			DestinationURL: "mem://localhost/completion/dest/completion.csv",
+			RetryURL:       "mem://localhost/completion/retry",
+			CorruptionURL:  "mem://localhost/completion/corruption",
+			Completion:     useCase.completion,
+		}
+		if !assert.Nil(t, config.Init(ctx, fs), useCase.description) {
+			continue
+		}
+		srv := New(config, fs, &destinationWriter{fs: fs}, NewReporter)
+		response := srv.Do(ctx, NewRequest(strings.NewReader("1\nbad\n3"), nil, "mem://localhost/data/completion.csv")).BaseResponse()
+		if useCase.hasError {
+			assert.Equal(t, "", response.CompletionID, useCase.description)
+			assert.Contains(t, strings.Join(response.Errors, "\n"), "unsupported message vendor", useCase.description)
+			continue
+		}
+		assert.NotEqual(t, "", response.CompletionID, useCase.description)
+		queue := mem.Singleton().Queue(useCase.completion.Dest)
+		if !assert.Equal(t, 1, len(queue), useCase.description) {
+			continue
+		}
+		message := <-queue
+		assert.Equal(t, response.SourceURL, message.Subject, useCase.description)
+		assert.Equal(t, "ok|corrupted", message.Attributes["Status"], useCase.description)
+		payload, err := message.Payload()
+		assert.Nil(t, err, useCase.description)
+		useCase.expect(t, payload)
+	}
+
+	config := &Config{Completion: &Completion{Dest: &mbus.Resource{Name: "completion", Vendor: "mem"}, Template: "{{.Status"}}
+	assert.NotNil(t, config.Init(ctx, fs))
+}
+
+type blockingPublisher struct{}
+
+func (p *blockingPublisher) Push(ctx context.Context, dest *mbus.Resource, message *mbus.Message) (*mbus.Confirmation, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestService_Do_CompletionDeadline(t *testing.T) {
+	mbus.Register("blocking", &blockingPublisher{})
+	config := &Config{
+		MaxRetries:          3,
+		DeadlineReductionMs: 200,
+		DestinationURL:      "mem://localhost/completion/dest/deadline.csv",
+		Completion:          &Completion{Dest: &mbus.Resource{Name: "completion", Vendor: "blocking"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	srv := New(config, afs.New(), &destinationWriter{fs: afs.New()}, NewReporter)
+	started := time.Now()
+	response := srv.Do(ctx, NewRequest(strings.NewReader("1\n2"), nil, "mem://localhost/data/completion-deadline.csv")).BaseResponse()
+	assert.True(t, time.Since(started) < 2*time.Second)
+	assert.Contains(t, strings.Join(response.Errors, "\n"), "failed to publish completion event")
+}
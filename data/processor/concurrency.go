@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"sync"
+	"time"
+)
+
+// Adaptive represents adaptive concurrency config, concurrency grows additively while Process latency and error rate are healthy
+// and shrinks multiplicatively otherwise (AIMD)
+type Adaptive struct {
+	MinConcurrency int     //min concurrency, 1 by default
+	MaxConcurrency int     //max concurrency, adaptive concurrency is enabled if set
+	WindowMs       int     //adjustment window, 1000 by default
+	MaxErrorRate   float64 //max retriable error rate in the window, 0.1 by default
+	MaxLatencyMs   int     //optional max average Process latency in the window
+}
+
+// Enabled returns true if adaptive concurrency is set
+func (a Adaptive) Enabled() bool {
+	return a.MaxConcurrency > 0
+}
+
+// concurrencyLimiter represents adaptive Process concurrency limiter
+type concurrencyLimiter struct {
+	config   Adaptive
+	limit    int
+	peak     int
+	inFlight int
+	changed  chan struct{}
+	calls    int
+	errors   int
+	latency  time.Duration
+	started  time.Time
+	mux      sync.Mutex
+}
+
+// acquire waits for Process slot, it returns false if no slot is available before deadline
+func (c *concurrencyLimiter) acquire(deadline time.Time) bool {
+	if c == nil {
+		return true
+	}
+	for {
+		c.mux.Lock()
+		if c.inFlight < c.limit {
+			c.inFlight++
+			c.mux.Unlock()
+			return true
+		}
+		changed := c.changed
+		c.mux.Unlock()
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// release releases Process slot, and adjusts concurrency once adjustment window elapses
+func (c *concurrencyLimiter) release(latency time.Duration, failed bool) {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.inFlight--
+	c.calls++
+	c.latency += latency
+	if failed {
+		c.errors++
+	}
+	if time.Since(c.started) >= time.Duration(c.config.WindowMs)*time.Millisecond {
+		c.adjust()
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// cancel releases Process slot acquired for a record that was not processed, the slot is not counted in the adjustment window
+func (c *concurrencyLimiter) cancel() {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.inFlight--
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *concurrencyLimiter) adjust() {
+	errorRate := float64(c.errors) / float64(c.calls)
+	avgLatency := c.latency / time.Duration(c.calls)
+	healthy := errorRate <= c.config.MaxErrorRate
+	if c.config.MaxLatencyMs > 0 && avgLatency > time.Duration(c.config.MaxLatencyMs)*time.Millisecond {
+		healthy = false
+	}
+	if healthy {
+		c.limit++
+	} else {
+		c.limit /= 2
+	}
+	if c.limit < c.config.MinConcurrency {
+		c.limit = c.config.MinConcurrency
+	}
+	if c.limit > c.config.MaxConcurrency {
+		c.limit = c.config.MaxConcurrency
+	}
+	if c.limit > c.peak {
+		c.peak = c.limit
+	}
+	c.calls, c.errors, c.latency = 0, 0, 0
+	c.started = time.Now()
+}
+
+// peakConcurrency returns max concurrency reached
+func (c *concurrencyLimiter) peakConcurrency() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.peak
+}
+
+func newConcurrencyLimiter(config Adaptive, concurrency int) *concurrencyLimiter {
+	if config.MinConcurrency < 1 {
+		config.MinConcurrency = 1
+	}
+	if config.MaxConcurrency < config.MinConcurrency {
+		config.MaxConcurrency = config.MinConcurrency
+	}
+	if config.WindowMs == 0 {
+		config.WindowMs = 1000
+	}
+	if config.MaxErrorRate == 0 {
+		config.MaxErrorRate = 0.1
+	}
+	if concurrency < config.MinConcurrency {
+		concurrency = config.MinConcurrency
+	}
+	if concurrency > config.MaxConcurrency {
+		concurrency = config.MaxConcurrency
+	}
+	return &concurrencyLimiter{config: config, limit: concurrency, peak: concurrency, changed: make(chan struct{}), started: time.Now()}
+}
+
+// workers returns number of worker routines and optional adaptive concurrency limiter
+func (s *Service) workers() (int, *concurrencyLimiter) {
+	if !s.Config.Adaptive.Enabled() {
+		return s.Config.Concurrency, nil
+	}
+	limiter := newConcurrencyLimiter(s.Config.Adaptive, s.Config.Concurrency)
+	return limiter.config.MaxConcurrency, limiter
+}
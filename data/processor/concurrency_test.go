@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter_Release(t *testing.T) {
+	var useCases = []struct {
+		description string
+		failed      bool
+		latency     time.Duration
+		expect      int
+	}{
+		{description: "healthy increase", expect: 2},
+		{description: "healthy increase", expect: 3},
+		{description: "healthy increase", expect: 4},
+		{description: "max concurrency", expect: 4},
+		{description: "latency decrease", latency: time.Second, expect: 2},
+		{description: "healthy increase", expect: 3},
+		{description: "error decrease", failed: true, expect: 1},
+		{description: "min concurrency", failed: true, expect: 1},
+	}
+	limiter := newConcurrencyLimiter(Adaptive{MaxConcurrency: 4, MaxLatencyMs: 100}, 1)
+	for _, useCase := range useCases {
+		assert.True(t, limiter.acquire(time.Now().Add(time.Second)), useCase.description)
+		limiter.started = time.Now().Add(-time.Hour)
+		limiter.release(useCase.latency, useCase.failed)
+		assert.Equal(t, useCase.expect, limiter.limit, useCase.description)
+	}
+	assert.Equal(t, 4, limiter.peakConcurrency())
+}
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	limiter := newConcurrencyLimiter(Adaptive{MaxConcurrency: 2}, 1)
+	assert.True(t, limiter.acquire(time.Now().Add(time.Second)))
+	assert.False(t, limiter.acquire(time.Now().Add(10*time.Millisecond)))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		limiter.release(time.Millisecond, false)
+	}()
+	assert.True(t, limiter.acquire(time.Now().Add(time.Second)))
+}
+
+func TestConcurrencyLimiter_Cancel(t *testing.T) {
+	limiter := newConcurrencyLimiter(Adaptive{MaxConcurrency: 2}, 1)
+	assert.True(t, limiter.acquire(time.Now().Add(time.Second)))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		limiter.cancel()
+	}()
+	assert.True(t, limiter.acquire(time.Now().Add(time.Second)), "cancelled slot is available")
+	assert.Equal(t, 0, limiter.calls, "cancelled slot is not counted")
+	assert.Equal(t, 1, limiter.inFlight)
+}
+
+func TestService_Do_Adaptive(t *testing.T) {
+	fs := afs.New()
+	cfg := &Config{Concurrency: 1,
+		MaxExecTimeMs:  2000,
+		DestinationURL: "mem://localhost/dest/adaptive-sum.txt",
+		Adaptive:       Adaptive{MaxConcurrency: 8, WindowMs: 1},
+	}
+	srv := New(cfg, fs, &sumProcessor{fs: fs, allSleep: true, sleepTime: 5 * time.Millisecond}, NewReporter)
+	reporter := srv.Do(context.Background(), NewRequest(strings.NewReader(strings.Repeat("1\n", 40)+"1"), nil, "mem://localhost/data/adaptive/numbers.txt"))
+	response := reporter.BaseResponse()
+	assert.EqualValues(t, 41, response.Processed)
+	assert.True(t, response.PeakConcurrency > 1, response.PeakConcurrency)
+	sum, err := fs.DownloadWithURL(context.Background(), cfg.DestinationURL)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "41", string(sum))
+	}
+}
@@ -26,24 +26,42 @@ type (
 		DeadlineReductionMs int // Deadline typically comes from Lambda ctx. Max exec time == Deadline - DeadlineReductionMs
 		LoaderDeadlineLagMs int // Loader will finish earlier than workers to let the latter complete
 		MaxRetries          int
+		Backoff             Backoff // optional retry delay, retry destination URL carries not before time
 		Concurrency         int
-		DestinationURL      string // Service processing data destination URL. This is a template, e.g. $gs://$mybucket/$prefix/$a.dat
+		RateLimit           RateLimit      // optional Processor.Process rate limit
+		Adaptive            Adaptive       // optional adaptive concurrency, Concurrency is used as initial concurrency
+		CircuitBreaker      CircuitBreaker // optional circuit breaker routing records to the retry destination while downstream fails
+		DestinationURL      string         // Service processing data destination URL. This is a template, e.g. $gs://$mybucket/$prefix/$a.dat
+		Routing             *Routing       // optional content based routing of processed records to named destinations
 		DestinationCodec    string
 		Destination         *config.Stream
 		RetryURL            string // destination for the data to be retried
 		FailedURL           string // destination for the data that has failed max retires
 		CorruptionURL       string /// destination for the corrupted data
 		MaxExecTimeMs       int    // default execution timeMs used when context does not come with deadline
+		RecordTimeoutMs     int    // optional Process call timeout, Process context is cancelled on record timeout or deadline
+		OnPanic             string // optional outcome of the record recovered from Process panic: retry (default) or corruption
 		OnDone              string //move or delete, (move moves data to process URL,or delete for delete)
 		OnDoneURL           string
 		ReaderBufferSize    int    //if set above zero uses afs Steam option
 		BatchSize           int    //number of data lines passed to processor (1 by default)
+		MemoryBudgetMB      int    //optional in-flight records budget, the loader blocks while records passed to workers exceed the budget
 		Sort                Sort   //optional sorting config
 		ScannerBufferMB     int    //use in case you see bufio.Scanner: token too long
 		MetricPort          int    //if specified HTTP endpoint port to expose metrics
-		RowTypeName         string // parquet/json row type
+		RowTypeName         string // parquet/json/csv row type
+		SourceType          string // optional source type (registered decoder name), detected by source URL extension by default
+		SchemaURL           string // optional JSON Schema location, JSON records violating the schema are written to the corruption destination without calling Process
 		OnMirrorURL         string //OnMirror represents copy url of the resource
 		QuorumExt           string
+		QuorumManifest      *QuorumManifest
+		Completion          *Completion
+		Partition           Partition // optional key partitioned processing, records with the same key are processed in order
+		Sampling            *Sampling // optional deterministic hash based sampling of processed records, the remaining records are passed through
+		Shadow              *Shadow   // optional shadow processing report config
+		Columns             []string  // optional CSV column names of sources without header, used by Sort.By, grouping and row type decoding
+		Envelope            bool      // if set, retry, failed and corruption records are wrapped with the error envelope, enveloped source records are unwrapped
+		CheckpointURL       string    // if set, timed out processing persists checkpoint to resume the same source instead of rewriting unprocessed data to the retry destination
 	}
 )
 
@@ -85,6 +103,14 @@ func (c *Config) ExpandDestination(startTime time.Time) *config.Stream {
 	return destination
 }
 
+// SourceTypeOf returns configured source type or source type detected by URL extension
+func (c Config) SourceTypeOf(URL string) string {
+	if c.SourceType != "" {
+		return c.SourceType
+	}
+	return DetectSourceType(URL)
+}
+
 // Deadline returns max execution time for a Processor
 func (c Config) Deadline(ctx context.Context) time.Time {
 	deadline, ok := ctx.Deadline()
@@ -118,6 +144,9 @@ func (c *Config) Validate() error {
 	if c.MaxExecTimeMs > math.MaxInt32 {
 		return errors.New("maxExecTimeMs too large")
 	}
+	if c.OnPanic != "" && c.OnPanic != PanicRetry && c.OnPanic != PanicCorruption {
+		return errors.New("unsupported onPanic: " + c.OnPanic)
+	}
 	return nil
 }
 
@@ -152,6 +181,23 @@ func (c *Config) Init(ctx context.Context, fs afs.Service) error {
 	if c.Concurrency == 0 {
 		c.Concurrency = 20
 	}
+	if c.SchemaURL != "" && c.SourceType != "" && !isJSONSource(c.SourceType) {
+		return errors.New("schemaURL is not supported with source type: " + c.SourceType)
+	}
+	if c.Sampling != nil && c.Sampling.PassThroughURL == "" { //records not sampled would be lost
+		return errors.New("sampling passThroughURL was empty")
+	}
+	if c.Envelope && c.RetryURL != "" && c.FailedURL == "" { //records exceeding max retries would be retried forever
+		return errors.New("failedURL was empty, it is required with envelope")
+	}
+	if c.Completion != nil {
+		if err := c.Completion.Init(); err != nil {
+			return err
+		}
+	}
+	if c.Routing != nil {
+		return c.Routing.Init()
+	}
 	return nil
 }
 
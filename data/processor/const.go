@@ -1,9 +1,16 @@
 package processor
 
+import "time"
+
 const (
-	uuidVar        = "$UUID"
-	timePathVar    = "$TimePath"
-	RetryFragment  = "-retry"
-	pathTimeLayout = "2006/01/02/03"
-	metricURI = "/v1/api/metric/"
+	uuidVar           = "$UUID"
+	timePathVar       = "$TimePath"
+	RetryFragment     = "-retry"
+	NotBeforeFragment = "-notbefore"
+	RangeFragment     = "-range"
+	pathTimeLayout    = "2006/01/02/03"
+	metricURI         = "/v1/api/metric/"
 )
+
+// cancellationGrace defines time given to a cancelled Process call to return
+const cancellationGrace = 50 * time.Millisecond
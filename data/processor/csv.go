@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// csvTagName represents struct field tag with CSV column name
+const csvTagName = "csv"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// csvMapper represents CSV row to struct decoder, struct fields are matched with column names by csv tag or field name
+type csvMapper struct {
+	rowType   reflect.Type
+	fields    [][]int //struct field index by column position, nil for unmapped columns
+	delimiter rune
+}
+
+// decode decodes CSV line into a new row type instance
+func (m *csvMapper) decode(line []byte) (interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(line))
+	reader.Comma = m.delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	values, err := reader.Read()
+	if err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("failed to read CSV record: %s, due to %v", line, err))
+	}
+	rowPtr := reflect.New(m.rowType)
+	row := rowPtr.Elem()
+	for i, value := range values {
+		if i >= len(m.fields) || m.fields[i] == nil {
+			continue
+		}
+		field := row.FieldByIndex(m.fields[i])
+		if err = setCSVValue(field, value); err != nil {
+			return nil, NewDataCorruption(fmt.Sprintf("failed to decode %v column %v: %s, due to %v", m.rowType.Name(), i, line, err))
+		}
+	}
+	return rowPtr.Interface(), nil
+}
+
+// setCSVValue sets field with text value, empty value leaves pointer field nil
+func setCSVValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		if value == "" {
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if field.Type() == timeType {
+		if value == "" {
+			return nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if ts, err := time.Parse(layout, value); err == nil {
+				field.Set(reflect.ValueOf(ts))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time: %v", value)
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+		return nil
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported field type: %v", field.Type())
+	}
+	return nil
+}
+
+func newCSVMapper(rowType reflect.Type, columns []string, delimiter string) (*csvMapper, error) {
+	if rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	if rowType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported CSV row type: %v", rowType)
+	}
+	comma, _ := utf8.DecodeRuneInString(delimiter)
+	result := &csvMapper{rowType: rowType, fields: make([][]int, len(columns)), delimiter: comma}
+	byName := map[string][]int{}
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		if field.PkgPath != "" { //unexported
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get(csvTagName); tag != "" {
+			if name = strings.Split(tag, ",")[0]; name == "-" {
+				continue
+			}
+		}
+		byName[strings.ToLower(name)] = field.Index
+	}
+	for i, column := range columns {
+		result.fields[i] = byName[strings.ToLower(strings.TrimSpace(column))]
+	}
+	return result, nil
+}
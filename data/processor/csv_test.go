@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type csvUser struct {
+	ID      int      `csv:"id"`
+	Name    string   `csv:"name"`
+	Amount  *float64 `csv:"amount"`
+	Created time.Time
+	Ignored string `csv:"-"`
+}
+
+func TestCSVMapper_Decode(t *testing.T) {
+	amount := 1.5
+	var useCases = []struct {
+		description string
+		columns     []string
+		delimiter   string
+		line        string
+		expect      *csvUser
+		hasError    bool
+	}{
+		{
+			description: "named columns",
+			columns:     []string{"name", "id", "amount", "created"},
+			delimiter:   ",",
+			line:        `"Doe, John",1,1.5,2024-01-02`,
+			expect:      &csvUser{ID: 1, Name: "Doe, John", Amount: &amount, Created: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			description: "empty pointer and unknown column",
+			columns:     []string{"id", "other", "amount"},
+			delimiter:   "\t",
+			line:        "2\tx\t",
+			expect:      &csvUser{ID: 2},
+		},
+		{
+			description: "invalid value",
+			columns:     []string{"id"},
+			delimiter:   ",",
+			line:        "abc",
+			hasError:    true,
+		},
+	}
+	for _, useCase := range useCases {
+		mapper, err := newCSVMapper(reflect.TypeOf(csvUser{}), useCase.columns, useCase.delimiter)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		actual, err := mapper.decode([]byte(useCase.line))
+		if useCase.hasError {
+			assert.True(t, isDataCorruptionError(err), useCase.description)
+			continue
+		}
+		assert.Nil(t, err, useCase.description)
+		assert.Equal(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+type csvUserProcessor struct {
+	mux   sync.Mutex
+	names []string
+}
+
+func (p *csvUserProcessor) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	switch actual := data.(type) {
+	case *csvUser:
+		if actual.Amount == nil {
+			return NewDataCorruption(fmt.Sprintf("missing amount: %v", actual.ID))
+		}
+		if *actual.Amount > 100 {
+			return errors.New("amount limit exceeded")
+		}
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		p.names = append(p.names, actual.Name)
+	case []byte:
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		p.names = append(p.names, string(actual))
+	}
+	return nil
+}
+
+func TestService_Do_CSVHeader(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	processor := &csvUserProcessor{}
+	cfg := &Config{
+		Concurrency:   1,
+		MaxExecTimeMs: 2000,
+		MaxRetries:    3,
+		RetryURL:      "mem://localhost/retry",
+		CorruptionURL: "mem://localhost/corruption",
+		Sort:          Sort{Spec: Spec{Format: "csv"}, By: []Field{{Name: "name"}}},
+	}
+	srv := New(cfg, fs, processor, NewReporter)
+	request := NewRequest(strings.NewReader("id,amount,name\n1,10,zed\n2,200,bob\n3,,carl\nx,1,ann\n4,5,adam"), nil, "mem://localhost/data/users.csv")
+	request.SourceType = CSVWithHeader
+	request.RowType = reflect.TypeOf(csvUser{})
+	response := srv.Do(ctx, request).BaseResponse()
+	assert.EqualValues(t, 2, response.Processed)
+	assert.Equal(t, []string{"adam", "zed"}, processor.names)
+	retry, err := fs.DownloadWithURL(ctx, response.RetryURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,amount,name\n2,200,bob", string(retry))
+	corrupted, err := fs.DownloadWithURL(ctx, response.CorruptionURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,amount,name\nx,1,ann\n3,,carl", string(corrupted))
+}
+
+func TestService_Do_CSVColumns(t *testing.T) {
+	processor := &csvUserProcessor{}
+	cfg := &Config{
+		Concurrency:   1,
+		MaxExecTimeMs: 2000,
+		Columns:       []string{"id", "team"},
+		Sort:          Sort{Spec: Spec{Format: "csv", Delimiter: ","}, By: []Field{{Name: "team"}}, Batch: true},
+	}
+	srv := New(cfg, afs.New(), processor, NewReporter)
+	response := srv.Do(context.Background(), NewRequest(strings.NewReader("1,b\n2,a\n3,b\n4,a"), nil, "mem://localhost/data/teams.csv")).BaseResponse()
+	assert.EqualValues(t, 2, response.Processed)
+	assert.Equal(t, []string{"2,a\n4,a", "1,b\n3,b"}, processor.names)
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+)
+
+type (
+	// Decoder represents source data decoder
+	Decoder interface {
+		// NewReader creates a record reader for the supplied request
+		NewReader(ctx context.Context, request *Request, config *Config) (RecordReader, error)
+	}
+
+	// BinaryDecoder represents an optional interface implemented by binary format decoders (i.e. parquet, avro),
+	// binary records are written to the retry and corruption destinations as new line delimited JSON
+	BinaryDecoder interface {
+		Decoder
+		// RandomAccess returns true if decoder reads source with request.ReaderAt
+		RandomAccess() bool
+	}
+
+	// RecordReader represents a record reader
+	RecordReader interface {
+		// Read returns the next record, io.EOF is returned once all records have been read,
+		// DataCorruption error is returned when a record can not be decoded
+		Read() (interface{}, error)
+		// Close closes the reader
+		Close() error
+	}
+
+	// SeekableReader represents an optional interface implemented by readers able to resume from a checkpoint byte offset
+	SeekableReader interface {
+		RecordReader
+		// Offset returns source byte offset of the next record, -1 if unknown
+		Offset() int64
+		// SeekOffset positions reader at the source byte offset, it returns false if source is not seekable
+		SeekOffset(offset int64) (bool, error)
+	}
+
+	// AttemptReader represents an optional interface implemented by readers unwrapping enveloped records
+	AttemptReader interface {
+		RecordReader
+		// Attempts returns processing attempts of the last read record, -1 if unknown
+		Attempts() int
+	}
+
+	// HeaderReader represents an optional interface implemented by CSV readers with column names
+	HeaderReader interface {
+		RecordReader
+		// Header returns column names, either read from the source header or configured
+		Header() []string
+		// HeaderLine returns source header line, empty if source has no header
+		HeaderLine() []byte
+	}
+
+	// RawReader represents an optional interface implemented by readers decoding text records into a row type,
+	// raw records are written to the retry and corruption destinations instead of the decoded ones
+	RawReader interface {
+		RecordReader
+		// Raw returns source line of the last read record, nil if record was not decoded
+		Raw() []byte
+	}
+
+	// DelimitedReader represents an optional interface implemented by delimited text readers,
+	// delimited records are raw lines that can be batched or grouped
+	DelimitedReader interface {
+		RecordReader
+		// Delimiter returns field delimiter
+		Delimiter() string
+	}
+)
@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectSourceType(t *testing.T) {
+	var useCases = []struct {
+		description string
+		URL         string
+		expect      string
+	}{
+		{description: "csv", URL: "s3://bucket/data/file.csv", expect: CSV},
+		{description: "compressed json", URL: "s3://bucket/data/file.json.gz", expect: JSON},
+		{description: "ndjson", URL: "gs://bucket/data/file-retry01.ndjson", expect: JSON},
+		{description: "tsv", URL: "gs://bucket/data/file.tsv", expect: TSV},
+		{description: "parquet", URL: "gs://bucket/data/file.parquet", expect: Parquet},
+		{description: "avro", URL: "gs://bucket/data/file.avro", expect: Avro},
+		{description: "unknown", URL: "gs://bucket/data/file", expect: CSV},
+	}
+	for _, useCase := range useCases {
+		assert.Equal(t, useCase.expect, DetectSourceType(useCase.URL), useCase.description)
+	}
+}
+
+func TestDecoder_NewReader(t *testing.T) {
+	var useCases = []struct {
+		description string
+		sourceType  string
+		input       []byte
+		expect      []string
+	}{
+		{
+			description: "tsv",
+			sourceType:  TSV,
+			input:       []byte("1\tfoo\n2\tbar"),
+			expect:      []string{"1\tfoo", "2\tbar"},
+		},
+		{
+			description: "csv with header",
+			sourceType:  CSVWithHeader,
+			input:       []byte("id,name\n1,foo\n2,bar\n"),
+			expect:      []string{"1,foo", "2,bar"},
+		},
+		{
+			description: "ndjson",
+			sourceType:  NDJSON,
+			input:       []byte(`{"id":1}` + "\n" + `{"id":2}`),
+			expect:      []string{`{"id":1}`, `{"id":2}`},
+		},
+		{
+			description: "avro",
+			sourceType:  Avro,
+			input: newTestAvroFile(`{"type":"record","name":"Row","fields":[{"name":"id","type":"long"},{"name":"name","type":["null","string"]}]}`,
+				func(buffer *bytes.Buffer) {
+					writeTestAvroLong(buffer, 1)
+					writeTestAvroLong(buffer, 1)
+					writeTestAvroString(buffer, "foo")
+				},
+				func(buffer *bytes.Buffer) {
+					writeTestAvroLong(buffer, 2)
+					writeTestAvroLong(buffer, 0)
+				}),
+			expect: []string{`{"id":1,"name":"foo"}`, `{"id":2,"name":null}`},
+		},
+	}
+	for _, useCase := range useCases {
+		request := NewRequest(bytes.NewReader(useCase.input), nil, "mem://localhost/data/input")
+		decoder := LookupDecoder(useCase.sourceType)
+		if !assert.NotNil(t, decoder, useCase.description) {
+			continue
+		}
+		reader, err := decoder.NewReader(context.Background(), request, &Config{})
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		var actual []string
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if !assert.Nil(t, err, useCase.description) {
+				break
+			}
+			actual = append(actual, string(record.([]byte)))
+		}
+		assert.Equal(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+func TestService_Do_SourceType(t *testing.T) {
+	fs := afs.New()
+	srv := New(&Config{Concurrency: 2, MaxExecTimeMs: 2000, DestinationURL: "mem://localhost/dest/sum.txt"},
+		fs, &sumProcessor{fs: fs}, NewReporter)
+	request := NewRequest(strings.NewReader("1\n2\n3"), nil, "mem://localhost/data/numbers.tsv")
+	request.SourceType = ""
+	reporter := srv.Do(context.Background(), request)
+	assert.Equal(t, TSV, request.SourceType)
+	assert.EqualValues(t, 3, reporter.BaseResponse().Processed)
+}
+
+func TestAvroReader_CorruptedSize(t *testing.T) {
+	schema := `{"type":"record","name":"Row","fields":[{"name":"name","type":"string"}]}`
+	empty := newTestAvroFile(schema)
+	header := empty[:len(empty)-2-avroSyncSize] //without empty block
+	var useCases = []struct {
+		description string
+		block       func(buffer *bytes.Buffer)
+	}{
+		{description: "negative block size", block: func(buffer *bytes.Buffer) {
+			writeTestAvroLong(buffer, 1)
+			writeTestAvroLong(buffer, -5)
+		}},
+		{description: "oversized block size", block: func(buffer *bytes.Buffer) {
+			writeTestAvroLong(buffer, 1)
+			writeTestAvroLong(buffer, 1<<40)
+			buffer.WriteString("abc")
+		}},
+		{description: "oversized string size", block: func(buffer *bytes.Buffer) {
+			writeTestAvroBlock(buffer, func(block *bytes.Buffer) {
+				writeTestAvroLong(block, 1<<40)
+				block.WriteString("abc")
+			})
+		}},
+		{description: "negative string size", block: func(buffer *bytes.Buffer) {
+			writeTestAvroBlock(buffer, func(block *bytes.Buffer) {
+				writeTestAvroLong(block, -3)
+			})
+		}},
+	}
+	for _, useCase := range useCases {
+		input := bytes.NewBuffer(append([]byte{}, header...))
+		useCase.block(input)
+		reader, err := LookupDecoder(Avro).NewReader(context.Background(), NewRequest(bytes.NewReader(input.Bytes()), nil, "mem://localhost/data/corrupted.avro"), &Config{})
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		_, err = reader.Read()
+		assert.True(t, isDataCorruptionError(err), useCase.description)
+		_, err = reader.Read()
+		assert.Equal(t, io.EOF, err, useCase.description)
+	}
+}
+
+func TestAvroReader_CorruptedBlock(t *testing.T) {
+	schema := `{"type":"record","name":"Row","fields":[{"name":"kind","type":{"type":"enum","name":"Kind","symbols":["a","b"]}},{"name":"note","type":["null","string"]}]}`
+	empty := newTestAvroFile(schema)
+	input := bytes.NewBuffer(append([]byte{}, empty[:len(empty)-2-avroSyncSize]...))
+	writeTestAvroBlock(input, func(block *bytes.Buffer) { //invalid enum index
+		writeTestAvroLong(block, 7)
+		writeTestAvroLong(block, 0)
+	})
+	writeTestAvroBlock(input, func(block *bytes.Buffer) { //invalid union index
+		writeTestAvroLong(block, 0)
+		writeTestAvroLong(block, 5)
+	})
+	writeTestAvroLong(input, 1) //invalid sync marker
+	writeTestAvroLong(input, 2)
+	writeTestAvroLong(input, 0)
+	writeTestAvroLong(input, 0)
+	input.Write(bytes.Repeat([]byte{'y'}, avroSyncSize))
+	writeTestAvroBlock(input, func(block *bytes.Buffer) {
+		writeTestAvroLong(block, 1)
+		writeTestAvroLong(block, 1)
+		writeTestAvroString(block, "ok")
+	})
+	reader, err := LookupDecoder(Avro).NewReader(context.Background(), NewRequest(bytes.NewReader(input.Bytes()), nil, "mem://localhost/data/corrupted.avro"), &Config{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		_, err = reader.Read()
+		assert.True(t, isDataCorruptionError(err), err)
+	}
+	data, err := reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"kind":"b","note":"ok"}`, string(data.([]byte)))
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func newTestAvroFile(schema string, records ...func(buffer *bytes.Buffer)) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(avroMagic)
+	writeTestAvroLong(buffer, 1)
+	writeTestAvroString(buffer, avroSchemaKey)
+	writeTestAvroString(buffer, schema)
+	writeTestAvroLong(buffer, 0)
+	sync := bytes.Repeat([]byte{'x'}, avroSyncSize)
+	buffer.Write(sync)
+	block := new(bytes.Buffer)
+	for _, record := range records {
+		record(block)
+	}
+	writeTestAvroLong(buffer, int64(len(records)))
+	writeTestAvroLong(buffer, int64(block.Len()))
+	buffer.Write(block.Bytes())
+	buffer.Write(sync)
+	return buffer.Bytes()
+}
+
+func writeTestAvroBlock(buffer *bytes.Buffer, record func(block *bytes.Buffer)) {
+	block := new(bytes.Buffer)
+	record(block)
+	writeTestAvroLong(buffer, 1)
+	writeTestAvroLong(buffer, int64(block.Len()))
+	buffer.Write(block.Bytes())
+	buffer.Write(bytes.Repeat([]byte{'x'}, avroSyncSize))
+}
+
+func writeTestAvroLong(buffer *bytes.Buffer, value int64) {
+	data := make([]byte, binary.MaxVarintLen64)
+	buffer.Write(data[:binary.PutVarint(data, value)])
+}
+
+func writeTestAvroString(buffer *bytes.Buffer, value string) {
+	writeTestAvroLong(buffer, int64(len(value)))
+	buffer.WriteString(value)
+}
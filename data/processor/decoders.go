@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+type decoders struct {
+	registry   map[string]Decoder
+	extensions map[string]string
+	sync.RWMutex
+}
+
+// Lookup returns registered decoder
+func (d *decoders) Lookup(name string) Decoder {
+	d.RWMutex.RLock()
+	defer d.RWMutex.RUnlock()
+	return d.registry[strings.ToLower(name)]
+}
+
+// Register registers decoder with optional file extensions
+func (d *decoders) Register(name string, decoder Decoder, extensions ...string) {
+	d.RWMutex.Lock()
+	defer d.RWMutex.Unlock()
+	name = strings.ToLower(name)
+	d.registry[name] = decoder
+	for _, ext := range extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		d.extensions[strings.ToLower(ext)] = name
+	}
+}
+
+// Extensions returns file extensions registered for the decoder name
+func (d *decoders) Extensions(name string) []string {
+	d.RWMutex.RLock()
+	defer d.RWMutex.RUnlock()
+	var result []string
+	name = strings.ToLower(name)
+	for ext, candidate := range d.extensions {
+		if candidate == name {
+			result = append(result, ext)
+		}
+	}
+	return result
+}
+
+// SourceType returns decoder name registered for the URL extension (compression extension is ignored)
+func (d *decoders) SourceType(URL string) string {
+	d.RWMutex.RLock()
+	defer d.RWMutex.RUnlock()
+	ext := strings.ToLower(path.Ext(strings.TrimSuffix(URL, ".gz")))
+	return d.extensions[ext]
+}
+
+var registeredDecoders = &decoders{registry: map[string]Decoder{}, extensions: map[string]string{}}
+
+// RegisterDecoder registers source decoder by name, and optional file extensions, i.e. .csv
+func RegisterDecoder(name string, decoder Decoder, extensions ...string) {
+	registeredDecoders.Register(name, decoder, extensions...)
+}
+
+// LookupDecoder returns decoder registered with the name
+func LookupDecoder(name string) Decoder {
+	return registeredDecoders.Lookup(name)
+}
+
+// DetectSourceType returns source type for the URL based on registered decoder extensions, CSV by default
+func DetectSourceType(URL string) string {
+	if sourceType := registeredDecoders.SourceType(URL); sourceType != "" {
+		return sourceType
+	}
+	return CSV
+}
+
+func init() {
+	RegisterDecoder(CSV, &textDecoder{delimiter: ","}, ".csv", ".txt")
+	RegisterDecoder(TSV, &textDecoder{delimiter: "\t"}, ".tsv")
+	RegisterDecoder(CSVWithHeader, &textDecoder{delimiter: ",", header: true})
+	RegisterDecoder(JSON, &textDecoder{json: true}, ".json", ".ndjson", ".jsonl")
+	RegisterDecoder(NDJSON, &textDecoder{json: true})
+	RegisterDecoder(Parquet, &parquetDecoder{}, ".parquet")
+	RegisterDecoder(Avro, &avroDecoder{}, ".avro")
+}
@@ -0,0 +1,101 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/data/processor"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/log"
+	"github.com/viant/tapper/msg"
+	"sync"
+)
+
+// Router represents content based router of processed records to named tapper streams
+type Router struct {
+	mux      sync.RWMutex
+	loggers  map[string]*log.Logger
+	routing  *processor.Routing
+	reporter processor.Reporter
+}
+
+// Log logs the message to the route matched by the record, records not matching any route are skipped
+func (r *Router) Log(record []byte, message *msg.Message) (string, error) {
+	route := r.routing.Match(record)
+	if route == "" {
+		return "", nil
+	}
+	logger, err := r.Get(route)
+	if err != nil {
+		return "", err
+	}
+	if err = logger.Log(message); err != nil {
+		return "", err
+	}
+	r.reporter.BaseResponse().AddRouted(route)
+	return route, nil
+}
+
+// Get gets or creates a route logger
+func (r *Router) Get(route string) (*log.Logger, error) {
+	r.mux.RLock()
+	logger, ok := r.loggers[route]
+	r.mux.RUnlock()
+	if ok {
+		return logger, nil
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if logger, ok = r.loggers[route]; ok {
+		return logger, nil
+	}
+	aRoute := r.routing.Lookup(route)
+	if aRoute == nil {
+		return nil, fmt.Errorf("unknown route: %v", route)
+	}
+	cfg := &config.Stream{
+		URL:          aRoute.URL,
+		Codec:        aRoute.Codec,
+		Rotation:     aRoute.Rotation,
+		StreamUpload: true,
+	}
+	var err error
+	if logger, err = log.New(cfg, "", afs.New()); err != nil {
+		return nil, fmt.Errorf("failed to create route %v logger with: %+v, due to %w", route, cfg, err)
+	}
+	r.loggers[route] = logger
+	return logger, nil
+}
+
+// Close closes all route loggers
+func (r *Router) Close() (err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, logger := range r.loggers {
+		if e := logger.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// dataRouterKey data router key
+type dataRouterKey string
+
+// DataRouterKey data router context key
+const DataRouterKey = dataRouterKey("dataRouter")
+
+// NewDataRouter creates a data router for config.Routing
+func NewDataRouter(ctx context.Context, reporter processor.Reporter) (context.Context, error) {
+	routing := reporter.BaseResponse().Routing
+	if routing == nil {
+		return nil, fmt.Errorf("routing was empty")
+	}
+	result := &Router{
+		routing:  routing,
+		reporter: reporter,
+		loggers:  map[string]*log.Logger{},
+	}
+	return context.WithValue(ctx, DataRouterKey, result), nil
+}
@@ -0,0 +1,63 @@
+package destination
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/data/processor"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/msg"
+	"strings"
+	"testing"
+)
+
+type routingProcessor struct {
+	msgProvider *msg.Provider
+}
+
+func (p *routingProcessor) Pre(ctx context.Context, reporter processor.Reporter) (context.Context, error) {
+	return NewDataRouter(ctx, reporter)
+}
+
+func (p *routingProcessor) Process(ctx context.Context, data interface{}, reporter processor.Reporter) error {
+	record := strings.Split(string(data.([]byte)), ",")
+	message := p.msgProvider.NewMessage()
+	defer message.Free()
+	message.PutString("ID", record[0])
+	message.PutString("Country", record[1])
+	_, err := ctx.Value(DataRouterKey).(*Router).Log(data.([]byte), message)
+	return err
+}
+
+func (p *routingProcessor) Post(ctx context.Context, reporter processor.Reporter) error {
+	return ctx.Value(DataRouterKey).(*Router).Close()
+}
+
+func TestRouter_Log(t *testing.T) {
+	fs := afs.New()
+	cfg := &processor.Config{
+		Concurrency:   2,
+		MaxExecTimeMs: 3000,
+		Routing: &processor.Routing{
+			Spec: processor.Spec{Format: "csv"},
+			Routes: []*processor.Route{
+				{Name: "us", Stream: config.Stream{URL: "mem://localhost/routes/us.json"}},
+				{Name: "eu", Stream: config.Stream{URL: "mem://localhost/routes/eu.json.gz", Codec: "gzip"}},
+				{Name: "other", Stream: config.Stream{URL: "mem://localhost/routes/other.json"}},
+			},
+			Rules: []*processor.Rule{
+				{Route: "us", Field: &processor.Field{Index: 1}, Equals: []string{"US"}},
+				{Route: "eu", Field: &processor.Field{Index: 1}, Match: "^(DE|FR)$"},
+			},
+			DefaultRoute: "other",
+		},
+	}
+	srv := processor.New(cfg, fs, &routingProcessor{msgProvider: msg.NewProvider(1024, 2)}, processor.NewReporter)
+	reporter := srv.Do(context.Background(), processor.NewRequest(strings.NewReader("1,US\n2,DE\n3,PL\n4,US\n5,FR"), nil, "mem://localhost/data/routes.csv"))
+	response := reporter.BaseResponse()
+	assert.Equal(t, processor.StatusOk, response.Status)
+	assert.Equal(t, map[string]int32{"us": 2, "eu": 2, "other": 1}, response.Routed)
+	data, err := fs.DownloadWithURL(context.Background(), "mem://localhost/routes/other.json")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"ID":"3","Country":"PL"}`+"\n", string(data))
+}
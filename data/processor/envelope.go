@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	//Envelope error classes
+	ErrorClassCorruption = "corruption"
+	ErrorClassPartial    = "partial"
+	ErrorClassProcess    = "process"
+	ErrorClassTimeout    = "timeout"
+	ErrorClassCircuit    = "circuit"
+)
+
+// errNotProcessed represents deadline exceeded before a record was passed to the Processor, it does not count as a processing attempt
+var errNotProcessed = fmt.Errorf("not processed: %w", context.DeadlineExceeded)
+
+// envelopePrefix identifies enveloped record, ErrorClass has to be the first Envelope field
+var envelopePrefix = []byte(`{"ErrorClass":`)
+
+// Envelope represents retry, failed or corruption record wrapped with the failure details
+type Envelope struct {
+	ErrorClass string
+	Error      string `json:",omitempty"`
+	Attempt    int    //number of record processing attempts, records not passed to the Processor before deadline are not counted
+	SourceURL  string
+	Line       int //source record number
+	Timestamp  time.Time
+	Record     string //original record
+}
+
+// UnwrapEnvelope returns enveloped record or data if data is not an envelope
+func UnwrapEnvelope(data []byte) []byte {
+	record, _ := unwrapEnvelope(data)
+	return record
+}
+
+// ResetEnvelope returns enveloped record with processing attempts reset or data if data is not an envelope
+func ResetEnvelope(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, envelopePrefix) {
+		return data, nil
+	}
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return data, nil
+	}
+	envelope.Attempt = 0
+	return json.Marshal(envelope)
+}
+
+// unwrapEnvelope returns enveloped record with its processing attempts, or data with -1 if data is not an envelope
+func unwrapEnvelope(data []byte) ([]byte, int) {
+	if !bytes.HasPrefix(data, envelopePrefix) {
+		return data, -1
+	}
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return data, -1
+	}
+	return []byte(envelope.Record), envelope.Attempt
+}
+
+func errorClass(err error) string {
+	switch err.(type) {
+	case *DataCorruption:
+		return ErrorClassCorruption
+	case *PartialRetry:
+		return ErrorClassPartial
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	if err == errCircuitOpen {
+		return ErrorClassCircuit
+	}
+	return ErrorClassProcess
+}
+
+func (s *Service) newEnvelope(request *Request) *Envelope {
+	if !s.Config.Envelope {
+		return nil
+	}
+	return &Envelope{SourceURL: request.SourceURL}
+}
+
+// writeEnvelope writes every data line wrapped with an envelope, lines exceeding max retries are written to the failed destination
+func (w *Writer) writeEnvelope(ctx context.Context, data []byte, rec *record, cause error) error {
+	for i, item := range bytes.Split(data, []byte{'\n'}) {
+		envelope := *w.envelope
+		envelope.ErrorClass = errorClass(cause)
+		if cause != nil {
+			envelope.Error = cause.Error()
+		}
+		envelope.Line = rec.lineAt(i)
+		if i < len(rec.attempts) {
+			envelope.Attempt = rec.attempts[i]
+		}
+		if cause != errNotProcessed && cause != errCircuitOpen {
+			envelope.Attempt++
+		}
+		envelope.Timestamp = time.Now()
+		envelope.Record = string(item)
+		encoded, err := json.Marshal(envelope)
+		if err != nil {
+			return err
+		}
+		writer := w
+		if w.failed != nil && envelope.Attempt > w.maxRetries {
+			writer = w.failed
+		}
+		if err = writer.Write(ctx, encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envelopeReader represents new line delimited reader unwrapping enveloped records
+type envelopeReader struct {
+	scanner *bufio.Scanner
+	pending []byte
+	offset  int
+	err     error
+}
+
+// Read reads unwrapped records, records are new line delimited
+func (r *envelopeReader) Read(out []byte) (int, error) {
+	for r.offset >= len(r.pending) {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if !r.scanner.Scan() {
+			if r.err = r.scanner.Err(); r.err == nil {
+				r.err = io.EOF
+			}
+			continue
+		}
+		r.pending = append(r.pending[:0], UnwrapEnvelope(r.scanner.Bytes())...)
+		r.pending = append(r.pending, '\n')
+		r.offset = 0
+	}
+	n := copy(out, r.pending[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func newEnvelopeReader(reader io.Reader, config *Config) io.Reader {
+	scanner := bufio.NewScanner(reader)
+	config.AdjustScannerBuffer(scanner)
+	return &envelopeReader{scanner: scanner}
+}
@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+)
+
+func TestUnwrapEnvelope(t *testing.T) {
+	enveloped, _ := json.Marshal(&Envelope{ErrorClass: ErrorClassProcess, Record: `{"id":1}`})
+	var useCases = []struct {
+		description string
+		input       string
+		expect      string
+	}{
+		{description: "enveloped record", input: string(enveloped), expect: `{"id":1}`},
+		{description: "json record", input: `{"id":1}`, expect: `{"id":1}`},
+		{description: "csv record", input: `1,foo`, expect: `1,foo`},
+		{description: "invalid envelope", input: `{"ErrorClass":1`, expect: `{"ErrorClass":1`},
+	}
+	for _, useCase := range useCases {
+		assert.Equal(t, useCase.expect, string(UnwrapEnvelope([]byte(useCase.input))), useCase.description)
+	}
+}
+
+func TestService_Do_Envelope(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	cfg := &Config{Concurrency: 2,
+		MaxExecTimeMs:  2000,
+		DestinationURL: "mem://localhost/dest/envelope-sum.txt",
+		RetryURL:       "mem://localhost/tmp/envelope/retry/",
+		CorruptionURL:  "mem://localhost/tmp/envelope/corruption/",
+		MaxRetries:     3,
+		Envelope:       true,
+	}
+	srv := New(cfg, fs, &sumProcessor{fs: fs, errorOnNumber: 3, err: errors.New("test error")}, NewReporter)
+	reporter := srv.Do(ctx, NewRequest(strings.NewReader("1\n2\n3\n4"), nil, "mem://localhost/data/envelope/numbers.txt"))
+	response := reporter.BaseResponse()
+	if !assert.NotEmpty(t, response.RetryURL) {
+		return
+	}
+	data, err := fs.DownloadWithURL(ctx, response.RetryURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	envelope := &Envelope{}
+	if !assert.Nil(t, json.Unmarshal(data, envelope)) {
+		return
+	}
+	assert.Equal(t, ErrorClassProcess, envelope.ErrorClass)
+	assert.Contains(t, envelope.Error, "test error")
+	assert.Equal(t, 1, envelope.Attempt)
+	assert.Equal(t, 2, envelope.Line)
+	assert.Equal(t, "mem://localhost/data/envelope/numbers.txt", envelope.SourceURL)
+	assert.Equal(t, "3", envelope.Record)
+
+	srv = New(cfg, fs, &sumProcessor{fs: fs}, NewReporter)
+	reporter = srv.Do(ctx, NewRequest(strings.NewReader(string(data)), nil, response.RetryURL))
+	assert.EqualValues(t, 1, reporter.BaseResponse().Processed)
+	assert.Empty(t, reporter.BaseResponse().Errors)
+	sum, err := fs.DownloadWithURL(ctx, cfg.DestinationURL)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "3", string(sum))
+	}
+
+	partial := NewTyped(func(ctx context.Context, record *typedEvent, reporter Reporter) error {
+		if record.ID == 3 {
+			return errors.New("test error")
+		}
+		return nil
+	}, mustCSVDecoder[typedEvent](",", "id", "name"))
+	cfg = &Config{Concurrency: 1, MaxExecTimeMs: 2000, MaxRetries: 3, BatchSize: 4, Envelope: true,
+		RetryURL: "mem://localhost/tmp/envelope/partial/retry/",
+	}
+	srv = New(cfg, fs, partial, NewReporter)
+	reporter = srv.Do(ctx, NewRequest(strings.NewReader("1,a\n2,b\n3,c\n4,d"), nil, "mem://localhost/data/envelope/partial.csv"))
+	data, err = fs.DownloadWithURL(ctx, reporter.BaseResponse().RetryURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var lines []int
+	for _, line := range strings.Split(string(data), "\n") {
+		envelope := &Envelope{}
+		if assert.Nil(t, json.Unmarshal([]byte(line), envelope)) {
+			lines = append(lines, envelope.Line)
+		}
+	}
+	assert.Equal(t, []int{2, 3}, lines) //partially retried records keep source record numbers
+}
+
+func TestService_Do_RecordRetries(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	cfg := &Config{Concurrency: 1,
+		MaxExecTimeMs:  2000,
+		DestinationURL: "mem://localhost/dest/attempts-sum.txt",
+		RetryURL:       "mem://localhost/tmp/attempts/retry/",
+		FailedURL:      "mem://localhost/tmp/attempts/failed/",
+		MaxRetries:     3,
+		Envelope:       true,
+	}
+	exhausted, _ := json.Marshal(&Envelope{ErrorClass: ErrorClassProcess, Attempt: 3, Record: "5"})
+	notProcessed, _ := json.Marshal(&Envelope{ErrorClass: ErrorClassTimeout, Attempt: 0, Record: "5"})
+	input := string(exhausted) + "\n" + string(notProcessed) + "\n5"
+	srv := New(cfg, fs, &sumProcessor{fs: fs, errorOnNumber: 5, err: errors.New("test error")}, NewReporter)
+	reporter := srv.Do(ctx, NewRequest(strings.NewReader(input), nil, "mem://localhost/data/attempts/numbers-retry01.txt"))
+	response := reporter.BaseResponse()
+	if !assert.NotEmpty(t, response.RetryURL) || !assert.NotEmpty(t, response.FailedURL) {
+		return
+	}
+	var useCases = []struct {
+		description string
+		URL         string
+		expect      []int
+	}{
+		{description: "records within max retries", URL: response.RetryURL, expect: []int{1, 2}},
+		{description: "records exceeding max retries", URL: response.FailedURL, expect: []int{4}},
+	}
+	for _, useCase := range useCases {
+		data, err := fs.DownloadWithURL(ctx, useCase.URL)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		var actual []int
+		for _, line := range strings.Split(string(data), "\n") {
+			envelope := &Envelope{}
+			if assert.Nil(t, json.Unmarshal([]byte(line), envelope), useCase.description) {
+				actual = append(actual, envelope.Attempt)
+			}
+		}
+		assert.ElementsMatch(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+func TestRecord_Subset(t *testing.T) {
+	rec := &record{data: []byte("1\n2\n3\n4"), line: 10, count: 4, attempts: []int{0, 1, 2, 3}}
+	var useCases = []struct {
+		description    string
+		data           string
+		expectLines    []int
+		expectAttempts []int
+	}{
+		{description: "tail", data: "3\n4", expectLines: []int{12, 13}, expectAttempts: []int{2, 3}},
+		{description: "gaps", data: "1\n4", expectLines: []int{10, 13}, expectAttempts: []int{0, 3}},
+		{description: "whole", data: "1\n2\n3\n4", expectLines: []int{10, 11, 12, 13}, expectAttempts: []int{0, 1, 2, 3}},
+		{description: "not a subset", data: "5", expectLines: []int{10, 11, 12, 13}, expectAttempts: []int{0, 1, 2, 3}},
+	}
+	for _, useCase := range useCases {
+		actual := rec.subset([]byte(useCase.data))
+		var lines []int
+		for i := 0; i < actual.count; i++ {
+			lines = append(lines, actual.lineAt(i))
+		}
+		assert.Equal(t, useCase.expectLines, lines, useCase.description)
+		assert.Equal(t, useCase.expectAttempts, actual.attempts, useCase.description)
+	}
+}
+
+type closeRecorder struct {
+	closed bool
+	err    error
+}
+
+func (c *closeRecorder) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func TestWriter_Close(t *testing.T) {
+	retryErr, failedErr := errors.New("retry close error"), errors.New("failed close error")
+	retry, failed := &closeRecorder{err: retryErr}, &closeRecorder{err: failedErr}
+	writer := &Writer{writer: retry, counter: 1, failed: &Writer{writer: failed, counter: 1}}
+	err := writer.Close()
+	assert.True(t, retry.closed)
+	assert.True(t, failed.closed)
+	assert.True(t, errors.Is(err, retryErr))
+	assert.True(t, errors.Is(err, failedErr))
+}
+
+func TestConfig_Init_Envelope(t *testing.T) {
+	var useCases = []struct {
+		description string
+		config      *Config
+		hasError    bool
+	}{
+		{description: "failed destination", config: &Config{Envelope: true, RetryURL: "mem://localhost/retry", FailedURL: "mem://localhost/failed"}},
+		{description: "no retry destination", config: &Config{Envelope: true}},
+		{description: "missing failed destination", config: &Config{Envelope: true, RetryURL: "mem://localhost/retry"}, hasError: true},
+	}
+	for _, useCase := range useCases {
+		err := useCase.config.Init(context.Background(), afs.New())
+		assert.Equal(t, useCase.hasError, err != nil, useCase.description)
+	}
+}
@@ -3,6 +3,7 @@ package processor
 //DataCorruption represents corruption error
 type DataCorruption struct {
 	message string
+	cause   error //optional classified error
 }
 
 //Error returns an error
@@ -10,6 +11,11 @@ func (e *DataCorruption) Error() string {
 	return e.message
 }
 
+// Unwrap returns classified error if any
+func (e *DataCorruption) Unwrap() error {
+	return e.cause
+}
+
 // NewDataCorruption returns data corruption error
 func NewDataCorruption(msg string) error {
 	return &DataCorruption{message: msg}
@@ -27,6 +33,8 @@ func isDataCorruptionError(err error) bool {
 type PartialRetry struct {
 	data    interface{}
 	message string
+	corrupt []byte //optional corrupted part of the data, written to the corruption destination
+	cause   error  //optional classified error
 }
 
 //Error returns an error
@@ -34,6 +42,11 @@ func (e *PartialRetry) Error() string {
 	return e.message
 }
 
+// Unwrap returns classified error if any
+func (e *PartialRetry) Unwrap() error {
+	return e.cause
+}
+
 // NewDataCorruption returns data corruption error
 func NewPartialRetry(msg string, data interface{}) error {
 	return &PartialRetry{message: msg, data: data}
@@ -62,12 +75,18 @@ func newRetryError(msg string) error {
 
 type processError struct {
 	message string
+	cause   error //optional classified error
 }
 
 func (e *processError) Error() string {
 	return e.message
 }
 
+// Unwrap returns classified error if any
+func (e *processError) Unwrap() error {
+	return e.cause
+}
+
 // newProcessError returns process error
 func newProcessError(msg string) error {
 	return &processError{message: msg}
@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/viant/afs"
+	"github.com/viant/afs/file"
+	"github.com/viant/afs/url"
+	"io"
+	"os"
+	"path"
+	"sort"
+)
+
+// orderExternally orders the reader data with memory bounded sorted runs, spilled to TempURL and k-way merged
+func (s Sort) orderExternally(reader io.Reader, config *Config, budget int) (io.Reader, error) {
+	ctx := context.Background()
+	fs := afs.New()
+	scanner := bufio.NewScanner(reader)
+	if config != nil {
+		config.AdjustScannerBuffer(scanner)
+	}
+	var sortables = &Sortables{
+		Sort:  s,
+		Items: make([][]byte, 0),
+	}
+	tempURL := url.Join(s.tempURL(), uuid.New().String())
+	var runs []string
+	size := 0
+	for scanner.Scan() {
+		bs := scanner.Bytes()
+		if len(bs) == 0 {
+			continue
+		}
+		item := make([]byte, len(bs)+1)
+		copy(item, bs)
+		item[len(item)-1] = '\n'
+		sortables.Items = append(sortables.Items, item)
+		if size += len(item); size < budget {
+			continue
+		}
+		runURL, err := sortables.writeRun(ctx, fs, tempURL, len(runs))
+		if err != nil {
+			removeRuns(ctx, fs, tempURL)
+			return nil, err
+		}
+		runs = append(runs, runURL)
+		sortables.Items = make([][]byte, 0)
+		size = 0
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		removeRuns(ctx, fs, tempURL)
+		return nil, err
+	}
+	if len(runs) == 0 { //input fits memory budget
+		sort.Sort(sortables)
+		return sortables.reader(), nil
+	}
+	if len(sortables.Items) > 0 {
+		runURL, err := sortables.writeRun(ctx, fs, tempURL, len(runs))
+		if err != nil {
+			removeRuns(ctx, fs, tempURL)
+			return nil, err
+		}
+		runs = append(runs, runURL)
+	}
+	return s.newMergeReader(ctx, fs, tempURL, runs, config)
+}
+
+// removeRuns removes sorted runs location
+func removeRuns(ctx context.Context, fs afs.Service, tempURL string) {
+	if exists, _ := fs.Exists(ctx, tempURL); exists {
+		_ = fs.Delete(ctx, tempURL)
+	}
+}
+
+func (s Sort) tempURL() string {
+	if s.TempURL != "" {
+		return s.TempURL
+	}
+	return file.Scheme + "://" + path.Join(os.TempDir(), "cloudless", "sort")
+}
+
+// writeRun sorts items and writes them to a run file
+func (s *Sortables) writeRun(ctx context.Context, fs afs.Service, tempURL string, index int) (string, error) {
+	sort.Sort(s)
+	runURL := url.Join(tempURL, fmt.Sprintf("run%05d.txt", index))
+	writer, err := fs.NewWriter(ctx, runURL, file.DefaultFileOsMode)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sort run: %v, due to %w", runURL, err)
+	}
+	bufWriter := bufio.NewWriter(writer)
+	for _, item := range s.Items {
+		if _, err = bufWriter.Write(item); err != nil {
+			_ = writer.Close()
+			return "", err
+		}
+	}
+	if err = bufWriter.Flush(); err != nil {
+		_ = writer.Close()
+		return "", err
+	}
+	return runURL, writer.Close()
+}
+
+type (
+	// sortRun represents sorted run cursor
+	sortRun struct {
+		scanner *bufio.Scanner
+		item    []byte
+	}
+
+	// sortRuns represents sorted run heap
+	sortRuns struct {
+		sort *Sort
+		runs []*sortRun
+	}
+
+	// mergeReader represents k-way merge reader of sorted runs
+	mergeReader struct {
+		ctx     context.Context
+		fs      afs.Service
+		tempURL string
+		readers []io.ReadCloser
+		heap    *sortRuns
+		pending []byte
+		offset  int
+		emitted int
+		err     error
+		closed  bool
+	}
+)
+
+func (r *sortRun) next() bool {
+	if !r.scanner.Scan() {
+		return false
+	}
+	bs := r.scanner.Bytes()
+	r.item = make([]byte, len(bs)+1)
+	copy(r.item, bs)
+	r.item[len(r.item)-1] = '\n'
+	return true
+}
+
+// Len is part of heap.Interface.
+func (h *sortRuns) Len() int {
+	return len(h.runs)
+}
+
+// Less is part of heap.Interface.
+func (h *sortRuns) Less(i, j int) bool {
+	return h.sort.less(h.runs[i].item, h.runs[j].item)
+}
+
+// Swap is part of heap.Interface.
+func (h *sortRuns) Swap(i, j int) {
+	h.runs[i], h.runs[j] = h.runs[j], h.runs[i]
+}
+
+// Push is part of heap.Interface.
+func (h *sortRuns) Push(x interface{}) {
+	h.runs = append(h.runs, x.(*sortRun))
+}
+
+// Pop is part of heap.Interface.
+func (h *sortRuns) Pop() interface{} {
+	last := len(h.runs) - 1
+	result := h.runs[last]
+	h.runs = h.runs[:last]
+	return result
+}
+
+func (s Sort) newMergeReader(ctx context.Context, fs afs.Service, tempURL string, runURLs []string, config *Config) (io.Reader, error) {
+	result := &mergeReader{ctx: ctx, fs: fs, tempURL: tempURL, heap: &sortRuns{sort: &s}}
+	for _, runURL := range runURLs {
+		reader, err := fs.OpenURL(ctx, runURL)
+		if err != nil {
+			result.close()
+			return nil, fmt.Errorf("failed to open sort run: %v, due to %w", runURL, err)
+		}
+		result.readers = append(result.readers, reader)
+		run := &sortRun{scanner: bufio.NewScanner(reader)}
+		if config != nil {
+			config.AdjustScannerBuffer(run.scanner)
+		}
+		if run.next() {
+			result.heap.runs = append(result.heap.runs, run)
+		}
+	}
+	heap.Init(result.heap)
+	return result, nil
+}
+
+// Read reads merged data, items are new line delimited
+func (r *mergeReader) Read(out []byte) (int, error) {
+	for r.offset >= len(r.pending) {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.heap.Len() == 0 {
+			r.err = io.EOF
+			r.close()
+			return 0, r.err
+		}
+		run := r.heap.runs[0]
+		item := run.item[:len(run.item)-1]
+		if run.next() {
+			heap.Fix(r.heap, 0)
+		} else {
+			if err := run.scanner.Err(); err != nil {
+				r.err = err
+			}
+			heap.Pop(r.heap)
+		}
+		r.pending = r.pending[:0]
+		if r.emitted > 0 {
+			r.pending = append(r.pending, '\n')
+		}
+		r.pending = append(r.pending, item...)
+		r.offset = 0
+		r.emitted++
+	}
+	n := copy(out, r.pending[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+// Close closes run readers and removes runs, it is called by the record reader if the merged data is not read till EOF
+func (r *mergeReader) Close() error {
+	r.close()
+	return nil
+}
+
+// close closes run readers and removes runs
+func (r *mergeReader) close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	for _, reader := range r.readers {
+		_ = reader.Close()
+	}
+	r.readers = nil
+	removeRuns(r.ctx, r.fs, r.tempURL)
+}
@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// ProcessFunc represents Process call
+	ProcessFunc func(ctx context.Context, data interface{}, reporter Reporter) error
+
+	// Interceptor represents Process middleware, it calls next to continue the chain,
+	// an interceptor implementing PreProcessor or PostProcessor is also called around the Processor Pre and Post
+	Interceptor interface {
+		Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error
+	}
+
+	// InterceptorFunc represents Interceptor function adapter
+	InterceptorFunc func(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error
+
+	// chain represents Processor wrapped with interceptors, the first interceptor is the outermost
+	chain struct {
+		processor    Processor
+		interceptors []Interceptor
+	}
+)
+
+// Process calls the function
+func (f ProcessFunc) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	return f(ctx, data, reporter)
+}
+
+// Intercept calls the function
+func (f InterceptorFunc) Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error {
+	return f(ctx, data, reporter, next)
+}
+
+// Process runs the interceptor chain
+func (c *chain) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	return c.next(0)(ctx, data, reporter)
+}
+
+func (c *chain) next(index int) ProcessFunc {
+	if index == len(c.interceptors) {
+		return c.processor.Process
+	}
+	return func(ctx context.Context, data interface{}, reporter Reporter) error {
+		return c.interceptors[index].Intercept(ctx, data, reporter, c.next(index+1))
+	}
+}
+
+// Use appends interceptors wrapping the Processor, interceptors are called in the order they were added
+func (s *Service) Use(interceptors ...Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// intercepted returns the Processor wrapped with interceptors
+func (s *Service) intercepted() Processor {
+	if len(s.interceptors) == 0 {
+		return s.Processor
+	}
+	return &chain{processor: s.Processor, interceptors: s.interceptors}
+}
+
+// preIntercept calls interceptors Pre in order
+func (s *Service) preIntercept(ctx context.Context, reporter Reporter) (context.Context, error) {
+	var err error
+	for _, interceptor := range s.interceptors {
+		if preProcess, ok := interceptor.(PreProcessor); ok {
+			if ctx, err = preProcess.Pre(ctx, reporter); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ctx, nil
+}
+
+// postIntercept calls interceptors Post in reverse order
+func (s *Service) postIntercept(ctx context.Context, reporter Reporter) error {
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		if postProcess, ok := s.interceptors[i].(PostProcessor); ok {
+			if err := postProcess.Post(ctx, reporter); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SlowRecordLogger represents interceptor logging records with Process call exceeding the threshold
+type SlowRecordLogger struct {
+	ThresholdMs   int
+	MaxRecordSize int                  //max logged record size, 256 by default
+	Log           func(message string) //slow records are only counted when empty
+}
+
+// Intercept logs slow record, slow records are counted in response SlowRecords
+func (l *SlowRecordLogger) Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error {
+	started := time.Now()
+	err := next(ctx, data, reporter)
+	elapsed := time.Since(started)
+	if elapsed < time.Duration(l.ThresholdMs)*time.Millisecond {
+		return err
+	}
+	response := reporter.BaseResponse()
+	atomic.AddInt32(&response.SlowRecords, 1)
+	if l.Log == nil {
+		return err
+	}
+	record := ""
+	if bs, ok := data.([]byte); ok {
+		record = string(bs)
+	} else {
+		record = fmt.Sprintf("%+v", data)
+	}
+	if l.MaxRecordSize > 0 && len(record) > l.MaxRecordSize {
+		record = record[:l.MaxRecordSize] + "..."
+	}
+	l.Log(fmt.Sprintf("slow record: %v ms, source: %v, record: %s", elapsed.Milliseconds(), response.SourceURL, record))
+	return err
+}
+
+// NewSlowRecordLogger creates slow record logger
+func NewSlowRecordLogger(thresholdMs int, log func(message string)) *SlowRecordLogger {
+	return &SlowRecordLogger{ThresholdMs: thresholdMs, MaxRecordSize: 256, Log: log}
+}
+
+// ErrorRule represents error classification rule, errors matching the pattern are mapped to the class:
+// corruption (DataCorruption), partial (PartialRetry of the whole record), process (retriable error) or ok (error is dropped)
+type ErrorRule struct {
+	Pattern string
+	Class   string
+	expr    *regexp.Regexp
+}
+
+// ErrorClassifier represents interceptor mapping Process errors by the first matching rule,
+// rules are compiled by NewErrorClassifier or on the first Intercept call
+type ErrorClassifier struct {
+	Rules []*ErrorRule
+	once  sync.Once
+	err   error
+}
+
+// Intercept classifies Process error, classified error wraps the original error, records are not processed with invalid rules
+func (c *ErrorClassifier) Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error {
+	if err := c.compile(); err != nil {
+		return err
+	}
+	err := next(ctx, data, reporter)
+	if err == nil {
+		return nil
+	}
+	for _, rule := range c.Rules {
+		if !rule.expr.MatchString(err.Error()) {
+			continue
+		}
+		switch rule.Class {
+		case ErrorClassCorruption:
+			return &DataCorruption{message: err.Error(), cause: err}
+		case ErrorClassPartial:
+			return &PartialRetry{message: err.Error(), cause: err} //the whole record is written to the retry destination
+		case ErrorClassProcess:
+			return &processError{message: err.Error(), cause: err}
+		case StatusOk:
+			return nil
+		}
+	}
+	return err
+}
+
+// compile validates rule classes and compiles rule patterns once
+func (c *ErrorClassifier) compile() error {
+	c.once.Do(func() {
+		for _, rule := range c.Rules {
+			switch rule.Class {
+			case ErrorClassCorruption, ErrorClassPartial, ErrorClassProcess, StatusOk:
+			default:
+				c.err = fmt.Errorf("unsupported error class: %v, pattern: %v", rule.Class, rule.Pattern)
+				return
+			}
+			var err error
+			if rule.expr, err = regexp.Compile(rule.Pattern); err != nil {
+				c.err = fmt.Errorf("invalid error pattern: %v, due to %w", rule.Pattern, err)
+				return
+			}
+		}
+	})
+	return c.err
+}
+
+// NewErrorClassifier creates error classifier
+func NewErrorClassifier(rules ...*ErrorRule) (*ErrorClassifier, error) {
+	result := &ErrorClassifier{Rules: rules}
+	if err := result.compile(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ContextValues represents interceptor injecting values into the Pre, Process and Post context
+type ContextValues map[interface{}]interface{}
+
+// Pre injects the values, Process and Post context derive from the Pre context
+func (v ContextValues) Pre(ctx context.Context, reporter Reporter) (context.Context, error) {
+	for key, value := range v {
+		ctx = context.WithValue(ctx, key, value)
+	}
+	return ctx, nil
+}
+
+// Intercept continues the chain, the values are injected with Pre
+func (v ContextValues) Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error {
+	return next(ctx, data, reporter)
+}
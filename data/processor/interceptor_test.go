@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tenantKeyType string
+
+const tenantKey = tenantKeyType("tenant")
+
+type traceInterceptor struct {
+	name  string
+	trace *[]string
+	mux   *sync.Mutex
+}
+
+func (i *traceInterceptor) add(item string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	*i.trace = append(*i.trace, item)
+}
+
+func (i *traceInterceptor) Intercept(ctx context.Context, data interface{}, reporter Reporter, next ProcessFunc) error {
+	i.add(i.name + ">")
+	err := next(ctx, data, reporter)
+	i.add("<" + i.name)
+	return err
+}
+
+func (i *traceInterceptor) Pre(ctx context.Context, reporter Reporter) (context.Context, error) {
+	i.add("pre:" + i.name)
+	return ctx, nil
+}
+
+func (i *traceInterceptor) Post(ctx context.Context, reporter Reporter) error {
+	i.add("post:" + i.name)
+	return nil
+}
+
+func TestService_Use(t *testing.T) {
+	var trace []string
+	mux := &sync.Mutex{}
+	srv := New(&Config{MaxExecTimeMs: 2000, MaxRetries: 3}, afs.New(), ProcessFunc(func(ctx context.Context, data interface{}, reporter Reporter) error {
+		mux.Lock()
+		defer mux.Unlock()
+		trace = append(trace, "process:"+string(data.([]byte)))
+		return nil
+	}), NewReporter)
+	srv.Use(&traceInterceptor{name: "a", trace: &trace, mux: mux}, &traceInterceptor{name: "b", trace: &trace, mux: mux})
+	response := srv.Do(context.Background(), NewRequest(strings.NewReader("1"), nil, "mem://localhost/data/use.csv")).BaseResponse()
+	assert.EqualValues(t, 1, response.Processed)
+	assert.Equal(t, []string{"pre:a", "pre:b", "a>", "b>", "process:1", "<b", "<a", "post:b", "post:a"}, trace)
+}
+
+var errInvalidFormat = errors.New("invalid format")
+
+func TestErrorClassifier_Intercept(t *testing.T) {
+	classifier, err := NewErrorClassifier(
+		&ErrorRule{Pattern: "invalid (format|date)", Class: ErrorClassCorruption},
+		&ErrorRule{Pattern: "^throttled", Class: ErrorClassPartial},
+		&ErrorRule{Pattern: "already exists", Class: StatusOk},
+		&ErrorRule{Pattern: "connection", Class: ErrorClassProcess},
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var useCases = []struct {
+		description string
+		err         error
+		expect      func(err error) bool
+	}{
+		{description: "no error", expect: func(err error) bool { return err == nil }},
+		{description: "corruption", err: errors.New("invalid date: 2020-13-01"), expect: isDataCorruptionError},
+		{description: "corruption wrapping error", err: errInvalidFormat, expect: func(err error) bool {
+			return isDataCorruptionError(err) && errors.Is(err, errInvalidFormat)
+		}},
+		{description: "partial", err: errors.New("throttled by upstream"), expect: isPartialRetryError},
+		{description: "ok", err: errors.New("record already exists"), expect: func(err error) bool { return err == nil }},
+		{description: "retriable corruption", err: NewDataCorruption("connection reset"), expect: func(err error) bool {
+			_, ok := err.(*processError)
+			var corruption *DataCorruption
+			return ok && errors.As(err, &corruption)
+		}},
+		{description: "not matched", err: errors.New("other"), expect: func(err error) bool { return err.Error() == "other" }},
+	}
+	for _, useCase := range useCases {
+		actual := classifier.Intercept(context.Background(), []byte("x"), NewReporter(), func(ctx context.Context, data interface{}, reporter Reporter) error {
+			return useCase.err
+		})
+		assert.True(t, useCase.expect(actual), useCase.description)
+	}
+
+	_, err = NewErrorClassifier(&ErrorRule{Pattern: "(", Class: ErrorClassCorruption})
+	assert.NotNil(t, err)
+	_, err = NewErrorClassifier(&ErrorRule{Pattern: "x", Class: "fatal"})
+	assert.NotNil(t, err)
+
+	literal := &ErrorClassifier{Rules: []*ErrorRule{{Pattern: "already exists", Class: StatusOk}}}
+	assert.Nil(t, literal.Intercept(context.Background(), []byte("x"), NewReporter(), func(ctx context.Context, data interface{}, reporter Reporter) error {
+		return errors.New("key already exists")
+	}), "literal classifier compiled on first use")
+	invalid := &ErrorClassifier{Rules: []*ErrorRule{{Pattern: "(", Class: StatusOk}}}
+	processed := false
+	assert.NotNil(t, invalid.Intercept(context.Background(), []byte("x"), NewReporter(), func(ctx context.Context, data interface{}, reporter Reporter) error {
+		processed = true
+		return nil
+	}), "literal classifier with invalid pattern")
+	assert.False(t, processed)
+}
+
+func TestService_Do_Interceptors(t *testing.T) {
+	var tenants []interface{}
+	var mux sync.Mutex
+	classifier, err := NewErrorClassifier(&ErrorRule{Pattern: "invalid", Class: ErrorClassCorruption})
+	if !assert.Nil(t, err) {
+		return
+	}
+	var logged []string
+	slowLogger := NewSlowRecordLogger(0, func(message string) {
+		mux.Lock()
+		defer mux.Unlock()
+		logged = append(logged, message)
+	})
+	slowLogger.MaxRecordSize = 3
+	srv := New(&Config{MaxExecTimeMs: 2000, MaxRetries: 3}, afs.New(), ProcessFunc(func(ctx context.Context, data interface{}, reporter Reporter) error {
+		mux.Lock()
+		tenants = append(tenants, ctx.Value(tenantKey))
+		mux.Unlock()
+		if string(data.([]byte)) == "bad" {
+			return errors.New("invalid record")
+		}
+		return nil
+	}), NewReporter)
+	srv.Use(ContextValues{tenantKey: "t1"}, slowLogger, classifier)
+	response := srv.Do(context.Background(), NewRequest(strings.NewReader("1\nbad\n12345"), nil, "mem://localhost/data/intercepted.csv")).BaseResponse()
+	assert.EqualValues(t, 2, response.Processed)
+	assert.EqualValues(t, 1, response.CorruptionErrors)
+	assert.EqualValues(t, 3, response.SlowRecords)
+	assert.Equal(t, []interface{}{"t1", "t1", "t1"}, tenants)
+	assert.Equal(t, 3, len(logged))
+	assert.Contains(t, strings.Join(logged, "\n"), "source: mem://localhost/data/intercepted.csv, record: 123...")
+}
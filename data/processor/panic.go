@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+const (
+	// PanicRetry writes record recovered from Process panic to the retry destination (default)
+	PanicRetry = "retry"
+	// PanicCorruption writes record recovered from Process panic to the corruption destination
+	PanicCorruption = "corruption"
+)
+
+// recovered converts Process panic into the configured record outcome, the first panic stack is kept in response PanicStack
+func (s *Service) recovered(cause interface{}, reporter Reporter) error {
+	response := reporter.BaseResponse()
+	if atomic.AddInt32(&response.Panics, 1) == 1 {
+		response.mutex.Lock()
+		response.PanicStack = string(debug.Stack())
+		response.mutex.Unlock()
+	}
+	message := fmt.Sprintf("recovered from panic: %v", cause)
+	if s.Config.OnPanic == PanicCorruption {
+		return NewDataCorruption(message)
+	}
+	return newProcessError(message)
+}
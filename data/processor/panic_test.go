@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+)
+
+func TestService_Do_Panic(t *testing.T) {
+	var useCases = []struct {
+		description     string
+		baseURL         string
+		onPanic         string
+		expectRetry     string
+		expectCorrupted string
+	}{
+		{description: "retry by default", baseURL: "mem://localhost/panic/default", expectRetry: "2\n4"},
+		{description: "corruption", baseURL: "mem://localhost/panic/corruption", onPanic: PanicCorruption, expectCorrupted: "2\n4"},
+	}
+	fs := afs.New()
+	ctx := context.Background()
+	for _, useCase := range useCases {
+		srv := New(&Config{
+			MaxExecTimeMs: 2000,
+			MaxRetries:    3,
+			OnPanic:       useCase.onPanic,
+			RetryURL:      useCase.baseURL + "/retry",
+			CorruptionURL: useCase.baseURL + "/corruption",
+		}, fs, ProcessFunc(func(ctx context.Context, data interface{}, reporter Reporter) error {
+			switch string(data.([]byte)) {
+			case "2":
+				var values map[string]int
+				values["x"]++ //poison record
+			case "4":
+				panic("poison record")
+			}
+			return nil
+		}), NewReporter)
+		response := srv.Do(ctx, NewRequest(strings.NewReader("1\n2\n3\n4"), nil, "mem://localhost/data/panic.csv")).BaseResponse()
+		assert.EqualValues(t, 2, response.Processed, useCase.description)
+		assert.EqualValues(t, 2, response.Panics, useCase.description)
+		assert.Contains(t, response.PanicStack, "panic_test.go", useCase.description)
+		for URL, expect := range map[string]string{response.RetryURL: useCase.expectRetry, response.CorruptionURL: useCase.expectCorrupted} {
+			data, err := fs.DownloadWithURL(ctx, URL)
+			if expect == "" {
+				assert.NotNil(t, err, useCase.description)
+				continue
+			}
+			lines := strings.Split(string(data), "\n")
+			assert.ElementsMatch(t, strings.Split(expect, "\n"), lines, useCase.description)
+		}
+		assert.Contains(t, strings.Join(response.Errors, "\n"), "recovered from panic", useCase.description)
+	}
+
+	assert.NotNil(t, (&Config{RetryURL: "r", FailedURL: "f", CorruptionURL: "c", OnPanic: "ignore"}).Validate())
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/vc42/parquet-go"
+	"io"
+	"reflect"
+)
+
+// parquetDecoder represents parquet decoder
+type parquetDecoder struct{}
+
+// RandomAccess returns true, parquet is read with io.ReaderAt
+func (d *parquetDecoder) RandomAccess() bool {
+	return true
+}
+
+// NewReader creates parquet record reader
+func (d *parquetDecoder) NewReader(ctx context.Context, request *Request, config *Config) (RecordReader, error) {
+	if request.RowType == nil {
+		return nil, fmt.Errorf("parquet row type was not registered: %v", request.SourceURL)
+	}
+	readerAt := request.ReaderAt
+	if readerAt == nil {
+		if request.ReadCloser == nil {
+			return nil, fmt.Errorf("reader was empty: %v", request.SourceURL)
+		}
+		data, err := io.ReadAll(request.ReadCloser)
+		if err != nil {
+			return nil, err
+		}
+		readerAt = bytes.NewReader(data)
+	}
+	return &parquetReader{reader: parquet.NewReader(readerAt), rowType: request.RowType}, nil
+}
+
+type parquetReader struct {
+	reader  *parquet.Reader
+	rowType reflect.Type
+}
+
+// Read reads parquet row
+func (r *parquetReader) Read() (interface{}, error) {
+	rowPtr := reflect.New(r.rowType).Interface()
+	if err := r.reader.Read(rowPtr); err != nil {
+		return nil, err
+	}
+	return rowPtr, nil
+}
+
+// Close closes parquet reader
+func (r *parquetReader) Close() error {
+	return r.reader.Close()
+}
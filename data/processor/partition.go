@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/viant/toolbox"
+	"hash/fnv"
+	"strings"
+)
+
+// Partition represents key partitioned processing config, records with the same key are processed in order by a dedicated worker
+type Partition struct {
+	Spec
+	By []Field //partition key fields, CSV field index or JSON field name
+}
+
+// Enabled returns true if partition key is set
+func (p Partition) Enabled() bool {
+	return len(p.By) > 0
+}
+
+// Key returns record partition key, data that can not be marshalled has an empty key
+func (p *Partition) Key(data interface{}) string {
+	bs, ok := data.([]byte)
+	if !ok {
+		var err error
+		if bs, err = json.Marshal(data); err != nil {
+			return ""
+		}
+	}
+	return p.key(bs)
+}
+
+func (p *Partition) key(data []byte) string {
+	spec := p.Spec
+	if strings.ToLower(spec.Format) == "csv" && spec.Delimiter == "" {
+		spec.Delimiter = ","
+	}
+	builder := strings.Builder{}
+	for i := range p.By {
+		if i > 0 {
+			builder.WriteByte(0x1f)
+		}
+		builder.WriteString(toolbox.AsString(p.By[i].Value(data, &spec)))
+	}
+	return builder.String()
+}
+
+// recordKey returns partition key of the record source line (decoded rows without source line are marshalled)
+func (p *Partition) recordKey(rec *record) (string, error) {
+	data, ok := rec.bytes(rec.data)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(rec.data); err != nil {
+			return "", fmt.Errorf("failed to marshal %T partition key, due to %w", rec.data, err)
+		}
+	}
+	return p.key(data), nil
+}
+
+// withColumns returns partition with CSV field indexes resolved by column names
+func (p Partition) withColumns(columns []string) (*Partition, error) {
+	sortBy, err := Sort{By: p.By}.withColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	p.By = sortBy.By
+	return &p, nil
+}
+
+// index returns record partition index
+func (p *Partition) index(key string, partitions int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(partitions))
+}
+
+// partition returns worker streams, with partitioning each worker gets a dedicated stream of records hashed by key
+func (s *Service) partition(stream chan *record, workers int, response *Response) []chan *record {
+	result := make([]chan *record, workers)
+	if !s.Config.Partition.Enabled() {
+		for i := range result {
+			result[i] = stream
+		}
+		return result
+	}
+	for i := range result {
+		result[i] = make(chan *record, 10)
+	}
+	go func() {
+		var partition *Partition
+		logged := false
+		for rec := range stream {
+			if partition == nil { //source columns are known once the first record is loaded
+				var err error
+				if partition, err = s.Config.Partition.withColumns(response.columns); err != nil {
+					response.LogError(err)
+					partition = &s.Config.Partition
+				}
+			}
+			key, err := partition.recordKey(rec)
+			if err != nil && !logged { //records with unknown key are processed by the same worker
+				response.LogError(err)
+				logged = true
+			}
+			result[partition.index(key, workers)] <- rec
+		}
+		for _, partition := range result {
+			close(partition)
+		}
+	}()
+	return result
+}
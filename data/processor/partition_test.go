@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPartition_Key(t *testing.T) {
+	var useCases = []struct {
+		description string
+		partition   Partition
+		data        interface{}
+		expect      string
+	}{
+		{
+			description: "csv field",
+			partition:   Partition{Spec: Spec{Format: "csv"}, By: []Field{{Index: 1}}},
+			data:        []byte("1,user1,10"),
+			expect:      "user1",
+		},
+		{
+			description: "tsv fields",
+			partition:   Partition{Spec: Spec{Format: "csv", Delimiter: "\t"}, By: []Field{{Index: 0}, {Index: 2}}},
+			data:        []byte("1\tuser1\t10"),
+			expect:      "1\x1f10",
+		},
+		{
+			description: "json field",
+			partition:   Partition{By: []Field{{Name: "user"}}},
+			data:        []byte(`{"id":1,"user":"user1"}`),
+			expect:      "user1",
+		},
+	}
+	for _, useCase := range useCases {
+		assert.Equal(t, useCase.expect, useCase.partition.Key(useCase.data), useCase.description)
+	}
+}
+
+func TestService_Partition(t *testing.T) {
+	var useCases = []struct {
+		description string
+		partition   Partition
+		columns     []string
+		record      func(i int) *record
+	}{
+		{
+			description: "decoded csv rows with source line",
+			partition:   Partition{Spec: Spec{Format: "csv"}, By: []Field{{Name: "name"}}},
+			columns:     []string{"id", "name"},
+			record: func(i int) *record {
+				return &record{data: &csvUser{ID: i, Name: fmt.Sprintf("user%d", i%7)}, raw: []byte(fmt.Sprintf("%d,user%d", i, i%7))}
+			},
+		},
+		{
+			description: "decoded rows without source line",
+			partition:   Partition{By: []Field{{Name: "Name"}}},
+			record: func(i int) *record {
+				return &record{data: &csvUser{ID: i, Name: fmt.Sprintf("user%d", i%7)}}
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		srv := New(&Config{Partition: useCase.partition}, afs.New(), &orderProcessor{}, NewReporter)
+		response := &Response{columns: useCase.columns}
+		stream := make(chan *record)
+		streams := srv.partition(stream, 4, response)
+		go func() {
+			for i := 0; i < 70; i++ {
+				stream <- useCase.record(i)
+			}
+			close(stream)
+		}()
+		workers := map[string]map[int]bool{}
+		var mux sync.Mutex
+		var wg sync.WaitGroup
+		for i := range streams {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for rec := range streams[worker] {
+					mux.Lock()
+					name := rec.data.(*csvUser).Name
+					if workers[name] == nil {
+						workers[name] = map[int]bool{}
+					}
+					workers[name][worker] = true
+					mux.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+		used := map[int]bool{}
+		for name, keyWorkers := range workers {
+			assert.Equal(t, 1, len(keyWorkers), useCase.description+": "+name)
+			for worker := range keyWorkers {
+				used[worker] = true
+			}
+		}
+		assert.Equal(t, 7, len(workers), useCase.description)
+		assert.True(t, len(used) > 1, useCase.description)
+		assert.Equal(t, 0, len(response.Errors), useCase.description)
+	}
+}
+
+type orderProcessor struct {
+	mux    sync.Mutex
+	values map[string][]string
+}
+
+func (p *orderProcessor) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	fields := strings.Split(string(data.([]byte)), ",")
+	time.Sleep(time.Duration(len(fields[1])%3) * time.Millisecond)
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.values[fields[0]] = append(p.values[fields[0]], fields[1])
+	return nil
+}
+
+func TestService_Do_Partition(t *testing.T) {
+	var lines []string
+	var expect = map[string][]string{}
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("user%d", i%7)
+		value := fmt.Sprintf("%d", i)
+		lines = append(lines, key+","+value)
+		expect[key] = append(expect[key], value)
+	}
+	processor := &orderProcessor{values: map[string][]string{}}
+	cfg := &Config{Concurrency: 4,
+		MaxExecTimeMs: 5000,
+		BatchSize:     10,
+		Partition:     Partition{Spec: Spec{Format: "csv"}, By: []Field{{Index: 0}}},
+	}
+	srv := New(cfg, afs.New(), processor, NewReporter)
+	reporter := srv.Do(context.Background(), NewRequest(strings.NewReader(strings.Join(lines, "\n")), nil, "mem://localhost/data/partition/users.csv"))
+	assert.EqualValues(t, 200, reporter.BaseResponse().Processed)
+	assert.Equal(t, expect, processor.values)
+}
@@ -2,6 +2,7 @@ package processor
 
 import (
 	"context"
+	"reflect"
 )
 
 //Processor represents data processor
@@ -14,7 +15,17 @@ type PreProcessor interface {
 	Pre(ctx context.Context, reporter Reporter) (context.Context, error)
 }
 
+// Keyer is an optional interface returning record key, used by per key rate limit
+type Keyer interface {
+	Key(data interface{}) string
+}
+
 // PostProcessor is an optional preprocessor interface
 type PostProcessor interface {
 	Post(ctx context.Context, reporter Reporter) error
 }
+
+// RowTyper is an optional interface returning processor record type, used when request.RowType was not set
+type RowTyper interface {
+	RowType() reflect.Type
+}
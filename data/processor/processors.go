@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type processors struct {
+	registry map[string]Processor
+	sync.RWMutex
+}
+
+// Lookup returns registered processor
+func (p *processors) Lookup(name string) Processor {
+	p.RWMutex.RLock()
+	defer p.RWMutex.RUnlock()
+	return p.registry[strings.ToLower(name)]
+}
+
+// Register registers processor
+func (p *processors) Register(name string, processor Processor) {
+	p.RWMutex.Lock()
+	defer p.RWMutex.Unlock()
+	p.registry[strings.ToLower(name)] = processor
+}
+
+// Names returns sorted registered processor names
+func (p *processors) Names() []string {
+	p.RWMutex.RLock()
+	defer p.RWMutex.RUnlock()
+	var result = make([]string, 0, len(p.registry))
+	for name := range p.registry {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+var registeredProcessors = &processors{registry: map[string]Processor{}}
+
+// RegisterProcessor registers processor by name, i.e. to run it with the command line tool
+func RegisterProcessor(name string, processor Processor) {
+	registeredProcessors.Register(name, processor)
+}
+
+// LookupProcessor returns processor registered with the name
+func LookupProcessor(name string) Processor {
+	return registeredProcessors.Lookup(name)
+}
+
+// ProcessorNames returns registered processor names
+func ProcessorNames() []string {
+	return registeredProcessors.Names()
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/viant/cloudless/data/processor/queue"
+	"os"
+)
+
+func main() {
+	queue.Run(os.Args[1:])
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/data/processor/cli"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	//ActionList lists queue entries
+	ActionList = "list"
+	//ActionSample samples queue entries records
+	ActionSample = "sample"
+	//ActionRequeue moves queue entries to the retry location
+	ActionRequeue = "requeue"
+	//ActionPurge deletes queue entries
+	ActionPurge = "purge"
+)
+
+// sampled represents sampled entry
+type sampled struct {
+	*Entry
+	Records []string
+}
+
+// Run runs queue management action and prints the result as JSON
+func Run(args []string) {
+	options := &Options{}
+	if _, err := flags.ParseArgs(options, args); err != nil {
+		log.Fatalln(err)
+	}
+	if err := run(context.Background(), afs.New(), options, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run(ctx context.Context, fs afs.Service, options *Options, writer io.Writer) error {
+	config, err := cli.LoadConfig(ctx, fs, options.ConfigURL)
+	if err != nil {
+		return err
+	}
+	service := New(config, fs)
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if options.Queue == "" {
+		summary, err := service.Summary(ctx)
+		if err != nil {
+			return err
+		}
+		return encoder.Encode(summary)
+	}
+	entries, err := service.List(ctx, options.Queue, &Filter{Match: options.Match, OlderThan: options.OlderThan, NewerThan: options.NewerThan})
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	switch strings.ToLower(options.Action) {
+	case ActionList, "":
+		result = entries
+	case ActionSample:
+		var samples []*sampled
+		for _, entry := range entries {
+			records, err := service.Sample(ctx, entry, options.Limit)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, &sampled{Entry: entry, Records: records})
+		}
+		result = samples
+	case ActionRequeue:
+		if result, err = service.Requeue(ctx, entries, options.DestURL); err != nil {
+			return err
+		}
+	case ActionPurge:
+		if result, err = service.Purge(ctx, entries); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported action: %v", options.Action)
+	}
+	return encoder.Encode(result)
+}
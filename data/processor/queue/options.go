@@ -0,0 +1,15 @@
+package queue
+
+import "time"
+
+// Options represents command line options
+type Options struct {
+	ConfigURL string        `short:"c" long:"config" description:"processor config URL (YAML or JSON)" required:"true"`
+	Queue     string        `short:"q" long:"queue" description:"queue: retry, failed or corruption, all queues are summarized if empty"`
+	Action    string        `short:"a" long:"action" description:"list, sample, requeue or purge" default:"list"`
+	Match     string        `short:"m" long:"match" description:"optional entry URL regular expression"`
+	OlderThan time.Duration `long:"older" description:"optional min entry age, i.e. 72h"`
+	NewerThan time.Duration `long:"newer" description:"optional max entry age, i.e. 1h"`
+	Limit     int           `short:"n" long:"limit" description:"number of sampled records per entry" default:"5"`
+	DestURL   string        `short:"d" long:"dest" description:"optional requeue destination, retry location by default"`
+}
@@ -0,0 +1,309 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/viant/afs"
+	"github.com/viant/afs/file"
+	"github.com/viant/afs/option"
+	"github.com/viant/afs/url"
+	"github.com/viant/cloudless/data/processor"
+	"github.com/viant/cloudless/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	//Retry retry queue
+	Retry = "retry"
+	//Failed failed queue
+	Failed = "failed"
+	//Corruption corruption queue
+	Corruption = "corruption"
+)
+
+// retryExpr matches retry file name, the retry location is also the processor trigger source with input files
+var retryExpr = regexp.MustCompile(regexp.QuoteMeta(processor.RetryFragment) + `\d{2}`)
+
+var fragmentExpr = regexp.MustCompile(regexp.QuoteMeta(processor.RetryFragment) + `\d{2}|` + regexp.QuoteMeta(processor.NotBeforeFragment) + `\d+`)
+
+type (
+	// Entry represents a queued file
+	Entry struct {
+		Queue     string
+		URL       string
+		Size      int64
+		Retry     int        `json:",omitempty"`
+		NotBefore *time.Time `json:",omitempty"`
+		ModTime   time.Time
+		AgeSec    int
+		path      string //path relative to the queue location
+	}
+
+	// Summary represents queue summary
+	Summary struct {
+		Queue     string
+		URL       string
+		Count     int
+		Size      int64
+		OldestSec int `json:",omitempty"`
+		NewestSec int `json:",omitempty"`
+	}
+
+	// Filter represents entry filter
+	Filter struct {
+		Match     string        //optional URL regular expression
+		OlderThan time.Duration //optional min entry age
+		NewerThan time.Duration //optional max entry age
+	}
+
+	// Service represents retry, failed and corruption queue management service
+	Service struct {
+		config *processor.Config
+		fs     afs.Service
+	}
+)
+
+// Locations returns configured queue locations
+func (s *Service) Locations() map[string]string {
+	var result = map[string]string{}
+	for queue, URL := range map[string]string{Retry: s.config.RetryURL, Failed: s.config.FailedURL, Corruption: s.config.CorruptionURL} {
+		if URL = baseURL(URL); URL != "" {
+			result[queue] = URL
+		}
+	}
+	return result
+}
+
+// Summary returns summary of every configured queue
+func (s *Service) Summary(ctx context.Context) ([]*Summary, error) {
+	var result []*Summary
+	for _, queue := range []string{Retry, Failed, Corruption} {
+		location, ok := s.Locations()[queue]
+		if !ok {
+			continue
+		}
+		entries, err := s.List(ctx, queue, nil)
+		if err != nil {
+			return nil, err
+		}
+		summary := &Summary{Queue: queue, URL: location, Count: len(entries)}
+		for _, entry := range entries {
+			summary.Size += entry.Size
+			if entry.AgeSec > summary.OldestSec {
+				summary.OldestSec = entry.AgeSec
+			}
+			if summary.NewestSec == 0 || entry.AgeSec < summary.NewestSec {
+				summary.NewestSec = entry.AgeSec
+			}
+		}
+		result = append(result, summary)
+	}
+	return result, nil
+}
+
+// List lists queue entries matching optional filter, the oldest entries are listed first
+func (s *Service) List(ctx context.Context, queue string, filter *Filter) ([]*Entry, error) {
+	location, ok := s.Locations()[queue]
+	if !ok {
+		return nil, fmt.Errorf("%v queue location was not configured", queue)
+	}
+	var expr *regexp.Regexp
+	if filter != nil && filter.Match != "" {
+		var err error
+		if expr, err = regexp.Compile(filter.Match); err != nil {
+			return nil, fmt.Errorf("invalid match: %v, due to %w", filter.Match, err)
+		}
+	}
+	if exists, _ := s.fs.Exists(ctx, location); !exists {
+		return nil, nil
+	}
+	objects, err := s.fs.List(ctx, location, option.NewRecursive(true))
+	if err != nil {
+		return nil, err
+	}
+	var result []*Entry
+	now := time.Now()
+	_, locationPath := url.Base(location, file.Scheme)
+	for _, object := range objects {
+		if object.IsDir() {
+			continue
+		}
+		_, objectPath := url.Base(object.URL(), file.Scheme)
+		request := &processor.Request{SourceURL: object.URL()}
+		entry := &Entry{
+			Queue:   queue,
+			URL:     object.URL(),
+			Size:    object.Size(),
+			Retry:   request.Retry(),
+			ModTime: object.ModTime(),
+			AgeSec:  int(now.Sub(object.ModTime()).Seconds()),
+			path:    strings.TrimPrefix(strings.TrimPrefix(objectPath, locationPath), "/"),
+		}
+		if !entry.isManaged() { //input files of the retry location are not queued
+			continue
+		}
+		if notBefore := request.NotBefore(); !notBefore.IsZero() {
+			entry.NotBefore = &notBefore
+		}
+		if filter.matches(entry, expr) {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ModTime.Before(result[j].ModTime)
+	})
+	return result, nil
+}
+
+// Sample returns up to limit records of the entry
+func (s *Service) Sample(ctx context.Context, entry *Entry, limit int) ([]string, error) {
+	reader, err := s.fs.OpenURL(ctx, entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	dataReader, err := ioutil.DataReader(reader, entry.URL)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	defer func() { _ = dataReader.Close() }()
+	scanner := bufio.NewScanner(dataReader)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	var result []string
+	for scanner.Scan() && len(result) < limit {
+		result = append(result, scanner.Text())
+	}
+	return result, scanner.Err()
+}
+
+// Requeue moves entries to the retry location (or the supplied destination) with retry counter and not before time reset,
+// enveloped records are rewritten with envelope attempts reset, it returns requeued URLs
+func (s *Service) Requeue(ctx context.Context, entries []*Entry, destURL string) ([]string, error) {
+	if destURL == "" {
+		destURL = s.Locations()[Retry]
+	}
+	if destURL == "" {
+		return nil, fmt.Errorf("requeue destination was empty, retry location was not configured")
+	}
+	if err := checkManaged(entries); err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, entry := range entries {
+		targetURL := url.Join(destURL, fragmentExpr.ReplaceAllString(entry.path, ""))
+		if targetURL == entry.URL {
+			continue
+		}
+		var err error
+		if s.config.Envelope {
+			err = s.rewrite(ctx, entry.URL, targetURL)
+		} else {
+			err = s.fs.Move(ctx, entry.URL, targetURL)
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to requeue %v, due to %w", entry.URL, err)
+		}
+		result = append(result, targetURL)
+	}
+	return result, nil
+}
+
+// rewrite writes source records to the target with envelope attempts reset, the source is deleted once the target is written
+func (s *Service) rewrite(ctx context.Context, sourceURL, targetURL string) error {
+	reader, err := s.fs.OpenURL(ctx, sourceURL)
+	if err != nil {
+		return err
+	}
+	dataReader, err := ioutil.DataReader(reader, sourceURL)
+	if err != nil {
+		_ = reader.Close()
+		return err
+	}
+	defer func() { _ = dataReader.Close() }()
+	scanner := bufio.NewScanner(dataReader)
+	s.config.AdjustScannerBuffer(scanner)
+	writer := processor.NewWriter(targetURL, s.fs)
+	for scanner.Scan() {
+		data, err := processor.ResetEnvelope(scanner.Bytes())
+		if err == nil {
+			err = writer.Write(ctx, data)
+		}
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return s.fs.Delete(ctx, sourceURL)
+}
+
+// Purge deletes entries, it returns deleted URLs
+func (s *Service) Purge(ctx context.Context, entries []*Entry) ([]string, error) {
+	if err := checkManaged(entries); err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, entry := range entries {
+		if err := s.fs.Delete(ctx, entry.URL); err != nil {
+			return result, fmt.Errorf("failed to purge %v, due to %w", entry.URL, err)
+		}
+		result = append(result, entry.URL)
+	}
+	return result, nil
+}
+
+// isManaged returns false for retry location input file, retry queue has only retry files (i.e. data-retry01.csv)
+func (e *Entry) isManaged() bool {
+	if e.Queue != Retry {
+		return true
+	}
+	_, name := url.Split(e.URL, file.Scheme)
+	return retryExpr.MatchString(name)
+}
+
+// checkManaged returns an error if any entry is a retry location input file
+func checkManaged(entries []*Entry) error {
+	for _, entry := range entries {
+		if !entry.isManaged() {
+			return fmt.Errorf("%v is not a retry file, retry location input files can not be purged or requeued", entry.URL)
+		}
+	}
+	return nil
+}
+
+func (f *Filter) matches(entry *Entry, expr *regexp.Regexp) bool {
+	if f == nil {
+		return true
+	}
+	age := time.Duration(entry.AgeSec) * time.Second
+	if f.OlderThan > 0 && age < f.OlderThan {
+		return false
+	}
+	if f.NewerThan > 0 && age > f.NewerThan {
+		return false
+	}
+	return expr == nil || expr.MatchString(entry.URL)
+}
+
+// baseURL returns location URL without macro expanded path, i.e. s3://bucket/retry/$TimePath -> s3://bucket/retry
+func baseURL(URL string) string {
+	if index := strings.Index(URL, "$"); index != -1 {
+		URL = URL[:strings.LastIndex(URL[:index], "/")+1]
+	}
+	return strings.TrimRight(URL, "/")
+}
+
+// New creates queue management service
+func New(config *processor.Config, fs afs.Service) *Service {
+	return &Service{config: config, fs: fs}
+}
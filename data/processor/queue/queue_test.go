@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/data/processor"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	fs := afs.New()
+	baseDir := t.TempDir()
+	config := &processor.Config{
+		RetryURL:      path.Join(baseDir, "retry"),
+		FailedURL:     path.Join(baseDir, "failed", "$TimePath"),
+		CorruptionURL: path.Join(baseDir, "corruption"),
+	}
+	files := map[string]time.Duration{
+		"failed/data/a-retry03.csv":                     72 * time.Hour,
+		"failed/data/b-retry03-notbefore1700000000.csv": time.Hour,
+		"retry/data/c-retry01.csv":                      time.Minute,
+		"retry/data/input.csv":                          time.Minute, //retry location is also the trigger source
+	}
+	for name, age := range files {
+		location := path.Join(baseDir, name)
+		assert.Nil(t, os.MkdirAll(path.Dir(location), 0755))
+		assert.Nil(t, os.WriteFile(location, []byte("1\n2\n3"), 0644))
+		modTime := time.Now().Add(-age)
+		assert.Nil(t, os.Chtimes(location, modTime, modTime))
+	}
+	service := New(config, fs)
+	assert.Equal(t, path.Join(baseDir, "failed"), service.Locations()[Failed])
+
+	summary, err := service.Summary(ctx)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(summary)) {
+		assert.Equal(t, Retry, summary[0].Queue)
+		assert.Equal(t, 1, summary[0].Count)
+		assert.Equal(t, Failed, summary[1].Queue)
+		assert.Equal(t, 2, summary[1].Count)
+		assert.EqualValues(t, 10, summary[1].Size)
+		assert.True(t, summary[1].OldestSec >= 72*3600)
+		assert.Equal(t, 0, summary[2].Count)
+	}
+
+	entries, err := service.List(ctx, Failed, &Filter{OlderThan: 24 * time.Hour})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, 3, entries[0].Retry)
+		records, err := service.Sample(ctx, entries[0], 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, records)
+	}
+
+	entries, err = service.List(ctx, Failed, &Filter{Match: "b-retry"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.NotNil(t, entries[0].NotBefore)
+		requeued, err := service.Requeue(ctx, entries, "")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{path.Join(baseDir, "retry/data/b.csv")}, requeued)
+	}
+
+	entries, err = service.List(ctx, Retry, &Filter{NewerThan: 10 * time.Minute})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	entries, err = service.List(ctx, Retry, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries)) //requeued b.csv and input.csv are input files
+	purged, err := service.Purge(ctx, entries)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(purged))
+	entries, err = service.List(ctx, Retry, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	input := []*Entry{{Queue: Retry, URL: path.Join(baseDir, "retry/data/input.csv"), path: "data/input.csv"}}
+	_, err = service.Purge(ctx, input)
+	assert.NotNil(t, err)
+	_, err = service.Requeue(ctx, input, path.Join(baseDir, "other"))
+	assert.NotNil(t, err)
+	for _, name := range []string{"retry/data/input.csv", "retry/data/b.csv"} {
+		_, err = os.Stat(path.Join(baseDir, name))
+		assert.Nil(t, err, name)
+	}
+
+	_, err = New(&processor.Config{}, fs).List(ctx, Failed, nil)
+	assert.NotNil(t, err)
+}
+
+func TestService_Requeue_Envelope(t *testing.T) {
+	ctx := context.Background()
+	fs := afs.New()
+	baseDir := t.TempDir()
+	config := &processor.Config{
+		RetryURL:  path.Join(baseDir, "retry"),
+		FailedURL: path.Join(baseDir, "failed"),
+		Envelope:  true,
+	}
+	enveloped, err := json.Marshal(&processor.Envelope{ErrorClass: processor.ErrorClassProcess, Attempt: 5, SourceURL: "s3://bucket/data/a.csv", Line: 3, Record: "4"})
+	assert.Nil(t, err)
+	location := path.Join(baseDir, "failed", "data", "a-retry05.csv")
+	assert.Nil(t, os.MkdirAll(path.Dir(location), 0755))
+	assert.Nil(t, os.WriteFile(location, append(append(enveloped, '\n'), "plain"...), 0644))
+
+	service := New(config, fs)
+	entries, err := service.List(ctx, Failed, nil)
+	assert.Nil(t, err)
+	requeued, err := service.Requeue(ctx, entries, "")
+	assert.Nil(t, err)
+	if !assert.Equal(t, []string{path.Join(baseDir, "retry/data/a.csv")}, requeued) {
+		return
+	}
+	data, err := os.ReadFile(requeued[0])
+	assert.Nil(t, err)
+	lines := bytes.Split(data, []byte("\n"))
+	if assert.Equal(t, 2, len(lines)) {
+		envelope := &processor.Envelope{}
+		assert.Nil(t, json.Unmarshal(lines[0], envelope))
+		assert.Equal(t, 0, envelope.Attempt)
+		assert.Equal(t, 3, envelope.Line)
+		assert.Equal(t, "4", envelope.Record)
+		assert.Equal(t, "plain", string(lines[1]))
+	}
+	_, err = os.Stat(location)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRun(t *testing.T) {
+	baseDir := t.TempDir()
+	configURL := path.Join(baseDir, "config.json")
+	location := path.Join(baseDir, "corruption", "x.csv")
+	assert.Nil(t, os.MkdirAll(path.Dir(location), 0755))
+	assert.Nil(t, os.WriteFile(location, []byte("bad\nworse"), 0644))
+	config, _ := json.Marshal(&processor.Config{CorruptionURL: path.Join(baseDir, "corruption")})
+	assert.Nil(t, os.WriteFile(configURL, config, 0644))
+
+	var useCases = []struct {
+		description string
+		options     *Options
+		expect      string
+		hasError    bool
+	}{
+		{description: "summary", options: &Options{ConfigURL: configURL}, expect: `"Count": 1`},
+		{description: "sample", options: &Options{ConfigURL: configURL, Queue: Corruption, Action: ActionSample, Limit: 1}, expect: `"bad"`},
+		{description: "unsupported action", options: &Options{ConfigURL: configURL, Queue: Corruption, Action: "copy"}, hasError: true},
+		{description: "missing config", options: &Options{ConfigURL: path.Join(baseDir, "missing.json")}, hasError: true},
+	}
+	for _, useCase := range useCases {
+		writer := new(bytes.Buffer)
+		err := run(context.Background(), afs.New(), useCase.options, writer)
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		assert.Nil(t, err, useCase.description)
+		assert.Contains(t, writer.String(), useCase.expect, useCase.description)
+	}
+}
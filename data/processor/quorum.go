@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/viant/afs/file"
+	"github.com/viant/afs/url"
+	"github.com/viant/cloudless/ioutil"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	quorumPollInterval      = 250 * time.Millisecond
+	defaultQuorumRetryDelay = time.Minute
+)
+
+type (
+	// QuorumManifest represents quorum manifest mode, the quorum file lists expected parts,
+	// only listed parts are merged and deleted once all of them are present and verified
+	QuorumManifest struct {
+		WaitMs       int //optional max time to wait for missing parts before the quorum request is deferred
+		RetryDelayMs int //deferred quorum request redelivery delay, 1 min by default
+	}
+
+	// Manifest represents quorum file content
+	Manifest struct {
+		Parts []*ManifestPart
+	}
+
+	// ManifestPart represents expected part, relative URL is resolved against the quorum file location
+	ManifestPart struct {
+		URL      string
+		Size     *int64 `json:",omitempty"` //optional part size
+		Checksum string `json:",omitempty"` //optional part checksum, hex encoded with md5 (default), sha1 or sha256 prefix, i.e. sha256:9f86d0...
+	}
+)
+
+// RetryDelay returns deferred quorum request redelivery delay
+func (q *QuorumManifest) RetryDelay() time.Duration {
+	if q.RetryDelayMs <= 0 {
+		return defaultQuorumRetryDelay
+	}
+	return time.Duration(q.RetryDelayMs) * time.Millisecond
+}
+
+// newHash returns checksum hash and expected sum
+func (p *ManifestPart) newHash() (hash.Hash, string, error) {
+	algorithm, sum := "md5", p.Checksum
+	if index := strings.Index(sum, ":"); index != -1 {
+		algorithm, sum = strings.ToLower(sum[:index]), sum[index+1:]
+	}
+	switch algorithm {
+	case "md5":
+		return md5.New(), strings.ToLower(sum), nil
+	case "sha1":
+		return sha1.New(), strings.ToLower(sum), nil
+	case "sha256":
+		return sha256.New(), strings.ToLower(sum), nil
+	}
+	return nil, "", fmt.Errorf("unsupported checksum algorithm: %v", algorithm)
+}
+
+// handleManifestQuorum merges manifest parts into a single source, it returns true if the request was deferred
+func (s *Service) handleManifestQuorum(ctx context.Context, request *Request, response *Response) (bool, error) {
+	manifest, err := s.loadManifest(ctx, request)
+	if err != nil {
+		return true, err
+	}
+	if missing, err := s.waitForParts(ctx, manifest); err != nil || len(missing) > 0 {
+		if err != nil {
+			return true, err
+		}
+		notBefore := time.Now().Add(s.Config.QuorumManifest.RetryDelay())
+		response.Status = StatusDeferred
+		response.NotBefore = &notBefore
+		return true, nil
+	}
+	quorumURL := request.SourceURL
+	mergedURL := strings.Replace(quorumURL, s.Config.QuorumExt, "", 1)
+	if err = s.mergeParts(ctx, mergedURL, manifest); err != nil {
+		_ = s.fs.Delete(ctx, mergedURL)
+		return false, err
+	}
+	for _, part := range manifest.Parts { //delete only listed parts
+		_ = s.fs.Delete(ctx, part.URL)
+	}
+	_ = s.fs.Delete(ctx, quorumURL)
+	request.SourceURL = mergedURL
+	response.SourceURL = mergedURL
+	request.ReadCloser, err = s.fs.OpenURL(ctx, mergedURL)
+	return false, err
+}
+
+// loadManifest reads quorum file manifest
+func (s *Service) loadManifest(ctx context.Context, request *Request) (*Manifest, error) {
+	reader := request.ReadCloser
+	if reader == nil {
+		var err error
+		if reader, err = s.fs.OpenURL(ctx, request.SourceURL); err != nil {
+			return nil, err
+		}
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	request.ReadCloser = nil
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("invalid quorum manifest: %v, due to %v", request.SourceURL, err))
+	}
+	if len(manifest.Parts) == 0 {
+		return nil, NewDataCorruption(fmt.Sprintf("quorum manifest parts were empty: %v", request.SourceURL))
+	}
+	parent, _ := url.Split(request.SourceURL, file.Scheme)
+	for _, part := range manifest.Parts {
+		if part.URL == "" {
+			return nil, NewDataCorruption(fmt.Sprintf("quorum manifest part URL was empty: %v", request.SourceURL))
+		}
+		if url.IsRelative(part.URL) {
+			part.URL = url.Join(parent, part.URL)
+		}
+	}
+	return manifest, nil
+}
+
+// waitForParts waits for missing parts until QuorumManifest.WaitMs elapses or the deadline, it returns still missing parts
+func (s *Service) waitForParts(ctx context.Context, manifest *Manifest) ([]string, error) {
+	waitUntil := time.Now().Add(time.Duration(s.Config.QuorumManifest.WaitMs) * time.Millisecond)
+	if deadline := s.Config.Deadline(ctx); deadline.Before(waitUntil) {
+		waitUntil = deadline
+	}
+	for {
+		var missing []string
+		for _, part := range manifest.Parts {
+			exists, err := s.fs.Exists(ctx, part.URL)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				missing = append(missing, part.URL)
+			}
+		}
+		if len(missing) == 0 || time.Now().Add(quorumPollInterval).After(waitUntil) {
+			return missing, nil
+		}
+		select {
+		case <-time.After(quorumPollInterval):
+		case <-ctx.Done():
+			return missing, nil
+		}
+	}
+}
+
+// mergeParts merges and verifies manifest parts in the listed order
+func (s *Service) mergeParts(ctx context.Context, mergedURL string, manifest *Manifest) error {
+	writer, err := s.fs.NewWriter(ctx, mergedURL, file.DefaultFileOsMode)
+	if err != nil {
+		return err
+	}
+	lines := &lineWriter{Writer: writer}
+	for _, part := range manifest.Parts {
+		if err = lines.terminate(); err == nil {
+			err = s.mergePart(ctx, part, lines)
+		}
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+func (s *Service) mergePart(ctx context.Context, part *ManifestPart, writer io.Writer) error {
+	reader, err := s.fs.OpenURL(ctx, part.URL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+	counter := &byteCounter{}
+	var source io.Reader = io.TeeReader(reader, counter)
+	var checksum hash.Hash
+	var expected string
+	if part.Checksum != "" {
+		if checksum, expected, err = part.newHash(); err != nil {
+			return NewDataCorruption(fmt.Sprintf("invalid part %v checksum, due to %v", part.URL, err))
+		}
+		source = io.TeeReader(source, checksum)
+	}
+	dataReader, err := ioutil.DataReader(source, part.URL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dataReader.Close() }()
+	if _, err = io.Copy(writer, dataReader); err != nil {
+		return err
+	}
+	if _, err = io.Copy(io.Discard, source); err != nil { //drain to verify the whole part
+		return err
+	}
+	if part.Size != nil && counter.size != *part.Size {
+		return NewDataCorruption(fmt.Sprintf("part %v size mismatch, expected %v, but had %v", part.URL, *part.Size, counter.size))
+	}
+	if checksum != nil {
+		if actual := hex.EncodeToString(checksum.Sum(nil)); actual != expected {
+			return NewDataCorruption(fmt.Sprintf("part %v checksum mismatch, expected %v, but had %v", part.URL, expected, actual))
+		}
+	}
+	return nil
+}
+
+type byteCounter struct {
+	size int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	return len(p), nil
+}
+
+// lineWriter represents a writer terminating the last written line before the next part
+type lineWriter struct {
+	io.Writer
+	last byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.last = p[len(p)-1]
+	}
+	return w.Writer.Write(p)
+}
+
+func (w *lineWriter) terminate() error {
+	if w.last == 0 || w.last == '\n' {
+		return nil
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
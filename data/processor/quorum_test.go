@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestService_Do_QuorumManifest(t *testing.T) {
+	part1, part2 := "1\n2", "3\n4"
+	checksum := md5.Sum([]byte(part2))
+	var useCases = []struct {
+		description   string
+		parts         map[string]string
+		manifest      string
+		expectSum     int
+		expectStatus  string
+		expectRemains []string
+		deferred      bool
+	}{
+		{
+			description:   "listed parts only",
+			parts:         map[string]string{"part1.csv": part1, "part2.csv": part2, "stray.csv": "100"},
+			manifest:      fmt.Sprintf(`{"Parts":[{"URL":"part1.csv","Size":3},{"URL":"part2.csv","Checksum":"%v"}]}`, hex.EncodeToString(checksum[:])),
+			expectSum:     10,
+			expectStatus:  StatusOk,
+			expectRemains: []string{"batch.csv", "stray.csv"},
+		},
+		{
+			description:   "missing part",
+			parts:         map[string]string{"part1.csv": part1},
+			manifest:      `{"Parts":[{"URL":"part1.csv"},{"URL":"part2.csv"}]}`,
+			expectStatus:  StatusDeferred,
+			expectRemains: []string{"batch.csv.qrm", "part1.csv"},
+			deferred:      true,
+		},
+		{
+			description:   "checksum mismatch",
+			parts:         map[string]string{"part1.csv": part1},
+			manifest:      `{"Parts":[{"URL":"part1.csv","Checksum":"sha256:abc"}]}`,
+			expectStatus:  (StatusSetOk | StatusSetCorrupted).String(),
+			expectRemains: []string{"batch.csv.qrm", "part1.csv"},
+		},
+		{
+			description:   "invalid manifest",
+			parts:         map[string]string{"part1.csv": part1},
+			manifest:      `{"Parts":`,
+			expectStatus:  (StatusSetOk | StatusSetCorrupted).String(),
+			expectRemains: []string{"batch.csv.qrm", "part1.csv"},
+		},
+	}
+	ctx := context.Background()
+	fs := afs.New()
+	for i, useCase := range useCases {
+		baseURL := fmt.Sprintf("mem://localhost/quorum/case%v/", i)
+		for name, content := range useCase.parts {
+			assert.Nil(t, fs.Upload(ctx, baseURL+name, 0644, strings.NewReader(content)), useCase.description)
+		}
+		quorumURL := baseURL + "batch.csv.qrm"
+		assert.Nil(t, fs.Upload(ctx, quorumURL, 0644, strings.NewReader(useCase.manifest)), useCase.description)
+		var sum int32
+		processor := NewTyped(func(ctx context.Context, value *int32, reporter Reporter) error {
+			atomic.AddInt32(&sum, *value)
+			return nil
+		})
+		srv := New(&Config{
+			Concurrency:    1,
+			MaxExecTimeMs:  2000,
+			MaxRetries:     3,
+			QuorumExt:      ".qrm",
+			QuorumManifest: &QuorumManifest{WaitMs: 300},
+		}, fs, processor, NewReporter)
+		reader, err := fs.OpenURL(ctx, quorumURL)
+		assert.Nil(t, err, useCase.description)
+		response := srv.Do(ctx, NewRequest(reader, nil, quorumURL)).BaseResponse()
+		assert.Equal(t, useCase.expectStatus, response.Status, useCase.description)
+		assert.Equal(t, useCase.deferred, response.NotBefore != nil, useCase.description)
+		assert.EqualValues(t, useCase.expectSum, sum, useCase.description)
+		objects, err := fs.List(ctx, baseURL)
+		assert.Nil(t, err, useCase.description)
+		var remains []string
+		for _, object := range objects {
+			if !object.IsDir() {
+				remains = append(remains, object.Name())
+			}
+		}
+		sort.Strings(remains)
+		assert.Equal(t, useCase.expectRemains, remains, useCase.description)
+	}
+}
@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit represents Processor.Process token bucket rate limit
+type RateLimit struct {
+	RecordsPerSec float64 //max records per second
+	BytesPerSec   float64 //optional max record bytes per second, applies to []byte records
+	Burst         int     //max records burst, 1 by default
+	PerKey        bool    //if set, limits apply to each record key, Processor has to implement Keyer
+}
+
+// Enabled returns true if rate limit is set
+func (r RateLimit) Enabled() bool {
+	return r.RecordsPerSec > 0 || r.BytesPerSec > 0
+}
+
+// refillTime returns time to refill empty token buckets
+func (r RateLimit) refillTime() time.Duration {
+	var result time.Duration
+	if r.RecordsPerSec > 0 {
+		burst := math.Max(float64(r.Burst), 1)
+		result = time.Duration(burst / r.RecordsPerSec * float64(time.Second))
+	}
+	if r.BytesPerSec > 0 && result < time.Second { //bytes burst is one second of bytes
+		result = time.Second
+	}
+	return result
+}
+
+// bucketSweepInterval represents min interval between idle key buckets eviction
+const bucketSweepInterval = time.Minute
+
+type (
+	// tokenBucket represents records and bytes token buckets
+	tokenBucket struct {
+		records *rate.Limiter
+		bytes   *rate.Limiter
+		idleAt  time.Time //time the bucket is refilled if not used anymore
+	}
+
+	// rateLimiter represents global or per key rate limiter
+	rateLimiter struct {
+		config  RateLimit
+		global  *tokenBucket
+		buckets map[string]*tokenBucket
+		refill  time.Duration //idle key bucket is evicted once refilled, new bucket has the same state
+		swept   time.Time
+		mux     sync.Mutex
+	}
+)
+
+func newTokenBucket(config RateLimit) *tokenBucket {
+	result := &tokenBucket{}
+	if config.RecordsPerSec > 0 {
+		burst := config.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		result.records = rate.NewLimiter(rate.Limit(config.RecordsPerSec), burst)
+	}
+	if config.BytesPerSec > 0 {
+		result.bytes = rate.NewLimiter(rate.Limit(config.BytesPerSec), int(math.Ceil(config.BytesPerSec)))
+	}
+	return result
+}
+
+// reserve reserves tokens, it returns reservations with the longest delay
+func (b *tokenBucket) reserve(now time.Time, records, size int) ([]*rate.Reservation, time.Duration) {
+	var result []*rate.Reservation
+	var delay time.Duration
+	for _, item := range []struct {
+		limiter *rate.Limiter
+		tokens  int
+	}{{b.records, records}, {b.bytes, size}} {
+		if item.limiter == nil || item.tokens <= 0 {
+			continue
+		}
+		burst := item.limiter.Burst()
+		for tokens := item.tokens; tokens > 0; tokens -= burst { //tokens exceeding burst are reserved in burst sized chunks
+			reservation := item.limiter.ReserveN(now, int(math.Min(float64(tokens), float64(burst))))
+			result = append(result, reservation)
+			if itemDelay := reservation.DelayFrom(now); itemDelay > delay {
+				delay = itemDelay
+			}
+		}
+	}
+	return result, delay
+}
+
+// reserve reserves record tokens with the global or key bucket, it returns reservations with the longest delay
+func (l *rateLimiter) reserve(key string, now time.Time, records, size int) ([]*rate.Reservation, time.Duration) {
+	if !l.config.PerKey {
+		return l.global.reserve(now, records, size)
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if now.Sub(l.swept) >= bucketSweepInterval {
+		l.sweep(now)
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(l.config)
+		l.buckets[key] = bucket
+	}
+	reservations, delay := bucket.reserve(now, records, size)
+	bucket.idleAt = now.Add(delay + l.refill)
+	return reservations, delay
+}
+
+// sweep evicts idle key buckets
+func (l *rateLimiter) sweep(now time.Time) {
+	l.swept = now
+	for key, bucket := range l.buckets {
+		if now.After(bucket.idleAt) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// wait waits till the record is allowed, it returns false if the record is not allowed before deadline
+func (l *rateLimiter) wait(ctx context.Context, key string, rec *record, deadline time.Time, response *Response) bool {
+	size := 0
+	if data, ok := rec.data.([]byte); ok {
+		size = len(data)
+	}
+	now := time.Now()
+	reservations, delay := l.reserve(key, now, rec.count, size)
+	if delay == 0 {
+		return true
+	}
+	cancel := func() {
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+	}
+	if now.Add(delay).After(deadline) {
+		cancel()
+		return false
+	}
+	atomic.AddInt32(&response.RateLimitWaits, 1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		cancel()
+		return false
+	}
+}
+
+func newRateLimiter(config RateLimit) *rateLimiter {
+	return &rateLimiter{config: config, global: newTokenBucket(config), buckets: map[string]*tokenBucket{}, refill: config.refillTime(), swept: time.Now()}
+}
+
+// rateLimit waits till the record is allowed by the rate limit, it returns false if the record is not allowed before deadline
+func (s *Service) rateLimit(ctx context.Context, rec *record, deadline time.Time, response *Response) bool {
+	if !s.Config.RateLimit.Enabled() {
+		return true
+	}
+	s.limiterOnce.Do(func() {
+		s.limiter = newRateLimiter(s.Config.RateLimit)
+	})
+	key := ""
+	if keyer, ok := s.Processor.(Keyer); ok {
+		key = keyer.Key(rec.data)
+	}
+	return s.limiter.wait(ctx, key, rec, deadline, response)
+}
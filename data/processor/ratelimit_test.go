@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"testing"
+	"time"
+)
+
+type keySumProcessor struct {
+	sumProcessor
+}
+
+func (p *keySumProcessor) Key(data interface{}) string {
+	return string(data.([]byte))[:1]
+}
+
+func TestService_Do_RateLimit(t *testing.T) {
+	fs := afs.New()
+	var useCases = []struct {
+		description     string
+		rateLimit       RateLimit
+		processor       Processor
+		input           string
+		maxExecTimeMs   int
+		expectMinTime   time.Duration
+		expectWaits     bool
+		expectRetry     string
+		expectProcessed int32
+	}{
+		{
+			description:     "records per sec",
+			rateLimit:       RateLimit{RecordsPerSec: 20},
+			processor:       &sumProcessor{fs: fs},
+			input:           "1\n2\n3\n4\n5\n6",
+			maxExecTimeMs:   2000,
+			expectMinTime:   200 * time.Millisecond,
+			expectWaits:     true,
+			expectProcessed: 6,
+		},
+		{
+			description:     "bytes per sec",
+			rateLimit:       RateLimit{BytesPerSec: 10},
+			processor:       &sumProcessor{fs: fs},
+			input:           "11111\n22222\n33333\n44444",
+			maxExecTimeMs:   3000,
+			expectMinTime:   time.Second,
+			expectWaits:     true,
+			expectProcessed: 4,
+		},
+		{
+			description:     "per key",
+			rateLimit:       RateLimit{RecordsPerSec: 1, PerKey: true},
+			processor:       &keySumProcessor{sumProcessor{fs: fs}},
+			input:           "1\n2\n3\n4",
+			maxExecTimeMs:   2000,
+			expectProcessed: 4,
+		},
+		{
+			description:     "deadline",
+			rateLimit:       RateLimit{RecordsPerSec: 1},
+			processor:       &sumProcessor{fs: fs},
+			input:           "1\n2\n3\n4",
+			maxExecTimeMs:   1500,
+			expectWaits:     true,
+			expectRetry:     "3\n4",
+			expectProcessed: 2,
+		},
+	}
+	for _, useCase := range useCases {
+		cfg := &Config{Concurrency: 1,
+			MaxExecTimeMs:  useCase.maxExecTimeMs,
+			DestinationURL: "mem://localhost/dest/ratelimit-sum.txt",
+			RetryURL:       "mem://localhost/tmp/ratelimit/retry/",
+			MaxRetries:     3,
+			RateLimit:      useCase.rateLimit,
+		}
+		srv := New(cfg, fs, useCase.processor, NewReporter)
+		started := time.Now()
+		reporter := srv.Do(context.Background(), NewRequest(strings.NewReader(useCase.input), nil, "mem://localhost/data/ratelimit/numbers.txt"))
+		response := reporter.BaseResponse()
+		assert.True(t, time.Since(started) >= useCase.expectMinTime, useCase.description)
+		assert.Equal(t, useCase.expectWaits, response.RateLimitWaits > 0, useCase.description)
+		assert.EqualValues(t, useCase.expectProcessed, response.Processed, useCase.description)
+		if useCase.expectRetry != "" {
+			retry, err := fs.DownloadWithURL(context.Background(), response.RetryURL)
+			assert.Nil(t, err, useCase.description)
+			assert.Equal(t, useCase.expectRetry, string(retry), useCase.description)
+		}
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(RateLimit{RecordsPerSec: 10})
+	reservations, delay := limiter.reserve("", now, 5, 0)
+	assert.Equal(t, 5, len(reservations)) //records exceeding burst are reserved in burst sized chunks
+	assert.True(t, delay >= 350*time.Millisecond && delay <= 450*time.Millisecond, delay.String())
+
+	limiter = newRateLimiter(RateLimit{RecordsPerSec: 10, PerKey: true})
+	limiter.reserve("a", now, 1, 0)
+	limiter.reserve("b", now, 1, 0)
+	assert.Equal(t, 2, len(limiter.buckets))
+	limiter.reserve("c", now.Add(2*bucketSweepInterval), 1, 0)
+	assert.Equal(t, 1, len(limiter.buckets)) //refilled idle buckets are evicted
+	_, delay = limiter.reserve("c", now.Add(2*bucketSweepInterval), 1, 0)
+	assert.True(t, delay > 0)
+}
@@ -0,0 +1,231 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync/atomic"
+)
+
+type (
+	// record represents a loaded source record
+	record struct {
+		data     interface{}
+		line     int   //source record number
+		count    int   //number of source records (batch or group)
+		attempts []int //prior processing attempts of every source record
+		// source record numbers if batched records are not consecutive
+		lines []int
+		// source line of the record decoded into a row type
+		raw []byte
+		// bytes accounted by the in-flight budget
+		size int64
+		// source line decode failure, the record is written to the corruption destination
+		corrupt error
+	}
+
+	// batch represents consecutive source lines
+	batch struct {
+		lines    [][]byte
+		line     int
+		attempts []int
+	}
+
+	// source represents record source tracking record numbers
+	source struct {
+		reader   RecordReader
+		progress *progress
+		response *Response
+		line     int
+		attempts int //prior processing attempts of records with unknown attempts
+	}
+)
+
+// bytes returns text data or source line of the decoded record data
+func (r *record) bytes(data interface{}) ([]byte, bool) {
+	if v, ok := data.([]byte); ok {
+		return v, true
+	}
+	if r != nil && r.raw != nil {
+		return r.raw, true
+	}
+	return nil, false
+}
+
+// lineAt returns source record number of the i-th batched record
+func (r *record) lineAt(i int) int {
+	if i < len(r.lines) {
+		return r.lines[i]
+	}
+	return r.line + i
+}
+
+// subset returns record of the data lines matched in order with the batched record lines, i.e. partial retry data,
+// the record itself is returned if data is not its lines subset
+func (r *record) subset(data []byte) *record {
+	source, ok := r.data.([]byte)
+	if !ok || r.count <= 1 || bytes.Equal(source, data) {
+		return r
+	}
+	lines := bytes.Split(source, []byte{'\n'})
+	result := &record{data: data}
+	index := 0
+	for _, item := range bytes.Split(data, []byte{'\n'}) {
+		for index < len(lines) && !bytes.Equal(lines[index], item) {
+			index++
+		}
+		if index == len(lines) {
+			return r
+		}
+		result.lines = append(result.lines, r.lineAt(index))
+		if index < len(r.attempts) {
+			result.attempts = append(result.attempts, r.attempts[index])
+		}
+		index++
+	}
+	result.line = result.lines[0]
+	result.count = len(result.lines)
+	return result
+}
+
+// weight returns record bytes, text data or source line length of the decoded record, estimated size of decoded row otherwise
+func (r *record) weight() int64 {
+	if data, ok := r.bytes(r.data); ok {
+		return int64(len(data))
+	}
+	return sizeOf(reflect.ValueOf(r.data))
+}
+
+func (b *batch) size() int {
+	return len(b.lines)
+}
+
+// isConsecutive returns true if line follows the batch lines
+func (b *batch) isConsecutive(line int) bool {
+	return len(b.lines) == 0 || line == b.line+len(b.lines)
+}
+
+func (b *batch) append(rec *record) {
+	if len(b.lines) == 0 {
+		b.line = rec.line
+	}
+	b.lines = append(b.lines, rec.data.([]byte))
+	b.attempts = append(b.attempts, rec.attempts...)
+}
+
+// flush returns batch record and resets the batch
+func (b *batch) flush() *record {
+	result := &record{data: bytes.Join(b.lines, []byte("\n")), line: b.line, count: len(b.lines), attempts: b.attempts}
+	b.lines = make([][]byte, 0)
+	b.attempts = nil
+	return result
+}
+
+func newSource(reader RecordReader, attempts int, progress *progress, response *Response) (*source, error) {
+	result := &source{reader: reader, attempts: attempts, progress: progress, response: response}
+	if checkpoint := progress.resumed(); checkpoint != nil && checkpoint.Offset > 0 {
+		if seekable, ok := reader.(SeekableReader); ok {
+			seeked, err := seekable.SeekOffset(checkpoint.Offset)
+			if err != nil {
+				return nil, err
+			}
+			if seeked {
+				result.line = checkpoint.Line
+			}
+		}
+	}
+	return result, nil
+}
+
+// offset returns source byte offset of the next record or -1 if unknown
+func (s *source) offset() int64 {
+	if seekable, ok := s.reader.(SeekableReader); ok {
+		return seekable.Offset()
+	}
+	return -1
+}
+
+// columns returns source column names, nil if unknown
+func (s *source) columns() []string {
+	if reader, ok := s.reader.(HeaderReader); ok {
+		return reader.Header()
+	}
+	return nil
+}
+
+// recordAttempts returns prior processing attempts of the last read record
+func (s *source) recordAttempts() int {
+	if reader, ok := s.reader.(AttemptReader); ok {
+		if attempts := reader.Attempts(); attempts >= 0 {
+			return attempts
+		}
+	}
+	return s.attempts
+}
+
+// next returns the next record or nil if there is no more records, records completed by the prior run are skipped
+func (s *source) next() *record {
+	for {
+		offset := s.offset()
+		data, err := s.reader.Read()
+		if err != nil {
+			if isDataCorruptionError(err) {
+				if rec := s.corrupted(err, offset); rec != nil {
+					return rec
+				}
+				s.response.LogError(err)
+				s.progress.read(s.line, offset)
+				s.progress.complete(s.line, 1)
+				s.line++
+				continue
+			}
+			if err != io.EOF {
+				s.response.LogError(err)
+			}
+			return nil
+		}
+		line := s.line
+		s.line++
+		s.progress.read(line, offset)
+		if s.progress.isCompleted(line) {
+			atomic.AddInt32(&s.response.CheckpointSkipped, 1)
+			continue
+		}
+		rec := &record{data: data, line: line, count: 1, attempts: []int{s.recordAttempts()}}
+		if reader, ok := s.reader.(RawReader); ok {
+			rec.raw = reader.Raw()
+		}
+		return rec
+	}
+}
+
+// corrupted returns record of the source line that failed to decode or nil if the line is unknown
+func (s *source) corrupted(err error, offset int64) *record {
+	reader, ok := s.reader.(RawReader)
+	if !ok || reader.Raw() == nil {
+		return nil
+	}
+	line := s.line
+	s.line++
+	s.progress.read(line, offset)
+	if s.progress.isCompleted(line) {
+		atomic.AddInt32(&s.response.CheckpointSkipped, 1)
+		return nil
+	}
+	raw := reader.Raw()
+	return &record{data: raw, raw: raw, line: line, count: 1, attempts: []int{s.recordAttempts()}, corrupt: err}
+}
+
+// nextLine returns the next delimited line record
+func (s *source) nextLine() *record {
+	rec := s.next()
+	if rec == nil {
+		return nil
+	}
+	if _, ok := rec.data.([]byte); !ok {
+		s.response.LogError(fmt.Errorf("expected: %T, but had: %T", []byte{}, rec.data))
+		return nil
+	}
+	return rec
+}
@@ -13,9 +13,13 @@ import (
 
 const (
 	//Source types
-	Parquet = "parquet"
-	JSON    = "json"
-	CSV     = "csv"
+	Parquet       = "parquet"
+	JSON          = "json"
+	NDJSON        = "ndjson"
+	CSV           = "csv"
+	CSVWithHeader = "csvh"
+	TSV           = "tsv"
+	Avro          = "avro"
 )
 
 // Request represents a processing request
@@ -24,6 +28,7 @@ type Request struct {
 	SourceType string
 	io.ReaderAt
 	RowType   reflect.Type
+	Range     *ByteRange
 	Attrs     map[string]interface{}
 	StartTime time.Time
 	SourceURL string //incoming original filename url
@@ -41,9 +46,17 @@ func (r *Request) Retry() int {
 
 }
 
+// NotBefore returns time before which retry source should not be processed, zero time if not set
+func (r *Request) NotBefore() time.Time {
+	return notBeforeOf(r.SourceURL)
+}
+
 // TransformSourceURL returns baseURL + sourceURL path
 func (r *Request) TransformSourceURL(baseURL string) string {
 	_, pathURL := url.Base(r.SourceURL, file.Scheme)
+	if r.Range != nil { //every range writes its own retry, corruption and checkpoint data
+		pathURL = withRange(pathURL, r.Range.Index)
+	}
 	return url.Join(baseURL, pathURL)
 }
 
@@ -14,6 +14,7 @@ type StatusSet int
 const (
 	StatusOk           = "ok"
 	StatusError        = "error"
+	StatusDeferred     = "deferred"
 	StatusSetOk        = StatusSet(1)
 	StatusSetError     = StatusSet(2)
 	StatusSetRetriable = StatusSet(4)
@@ -45,24 +46,78 @@ func (s StatusSet) String() string {
 
 // Response represents base processing response
 type Response struct {
-	Status           string
-	statusSet        StatusSet
-	Errors           []string `json:",omitempty"`
-	mutex            sync.Mutex
-	StartTime        time.Time
-	RuntimeMs        int
-	SourceURL        string `json:",omitempty"`
-	Destination 	 *config.Stream
-	RetryURL         string `json:"-"`          // destination for the data to be replayed
-	CorruptionURL    string `json:"-"`
-	Processed        int32  `json:",omitempty"`
-	RetryErrors      int32  `json:",omitempty"`
-	CorruptionErrors int32  `json:",omitempty"`
-	RetriableErrors  int32  `json:",omitempty"`
-	Loaded           int32  `json:",omitempty"`
-	LoadTimeouts     int32  `json:",omitempty"`
-	Batched          int32  `json:",omitempty"`
-	Skipped          int32  `json:",omitempty"`
+	Status            string
+	statusSet         StatusSet
+	Errors            []string `json:",omitempty"`
+	mutex             sync.Mutex
+	StartTime         time.Time
+	RuntimeMs         int
+	SourceURL         string `json:",omitempty"`
+	Destination       *config.Stream
+	RetryURL          string     `json:"-"` // destination for the data to be replayed
+	FailedURL         string     `json:"-"` // destination for the records exceeding max retries, if retries are accounted per record
+	CorruptionURL     string     `json:"-"`
+	Processed         int32      `json:",omitempty"`
+	RetryErrors       int32      `json:",omitempty"`
+	CorruptionErrors  int32      `json:",omitempty"`
+	RetriableErrors   int32      `json:",omitempty"`
+	Loaded            int32      `json:",omitempty"`
+	LoadTimeouts      int32      `json:",omitempty"`
+	Batched           int32      `json:",omitempty"`
+	Skipped           int32      `json:",omitempty"`
+	CheckpointURL     string     `json:",omitempty"` // checkpoint location if source processing has not been completed
+	CheckpointSkipped int32      `json:",omitempty"` // number of records completed by prior run
+	NotBefore         *time.Time `json:",omitempty"` // time before which deferred source should not be processed
+	RateLimitWaits    int32      `json:",omitempty"` // number of records delayed by rate limit
+	PeakConcurrency   int32      `json:",omitempty"` // max concurrency reached with adaptive concurrency
+	CircuitTripped    bool       `json:",omitempty"` // true if records were routed to the retry destination by open circuit
+	ProcessLeaks      int32      `json:",omitempty"` // number of Process calls that have not returned after cancellation
+	Sampled           int32      `json:",omitempty"` // number of records sampled for processing
+	PassedThrough     int32      `json:",omitempty"` // number of records not sampled, written to the pass-through location
+	PassThroughURL    string     `json:",omitempty"` // location of the records not sampled
+	ShadowProcessed   int32      `json:",omitempty"` // number of records processed by the shadow processor
+	ShadowMismatches  int32      `json:",omitempty"` // number of records with primary and shadow processor disagreement
+	ShadowReportURL   string     `json:",omitempty"` // shadow comparison report location
+	PeakInFlightBytes int64      `json:",omitempty"` // max bytes of records passed to workers and not yet processed
+	SlowRecords       int32      `json:",omitempty"` // number of records logged by SlowRecordLogger
+	Panics            int32      `json:",omitempty"` // number of Process calls recovered from panic
+	PanicStack        string     `json:",omitempty"` // stack trace of the first recovered panic
+	CompletionID      string     `json:",omitempty"` // published completion event message ID
+
+	Routing *Routing         `json:"-"`          // expanded content based routing
+	Routed  map[string]int32 `json:",omitempty"` // number of records written to each route
+	header  []byte           //source header written to the retry and corruption destinations
+	written map[string]bool  //retry, failed and corruption URLs with any records written
+	columns []string         //source column names, set before records are streamed
+}
+
+// AddRouted increments number of records written to the route
+func (r *Response) AddRouted(route string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.Routed == nil {
+		r.Routed = map[string]int32{}
+	}
+	r.Routed[route]++
+}
+
+// addWritten records writer URL if any records were written
+func (r *Response) addWritten(writer *Writer) {
+	if writer == nil || writer.counter == 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.written == nil {
+		r.written = map[string]bool{}
+	}
+	r.written[writer.url] = true
+}
+
+func (r *Response) tripCircuit() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.CircuitTripped = true
 }
 
 // LogError logs error
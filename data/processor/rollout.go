@@ -0,0 +1,282 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/francoispqt/gojay"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sync/atomic"
+)
+
+type (
+	// Sampling represents deterministic hash based record sampling, only sampled records are processed,
+	// the remaining records are written to the pass-through location
+	Sampling struct {
+		Spec
+		By             []Field //optional sampling key fields, CSV field index or JSON field name, the whole record is hashed by default
+		Rate           float64 //fraction of processed records, from 0 to 1
+		PassThroughURL string  //location of the records not sampled, source path is appended as with RetryURL
+	}
+
+	// Shadow represents shadow processing config, the shadow processor is set with Service.SetShadow
+	Shadow struct {
+		ReportURL string //optional comparison report location, source path is appended as with RetryURL
+	}
+
+	// ShadowResult represents primary and shadow processor disagreement
+	ShadowResult struct {
+		SourceURL    string
+		Line         int
+		Record       string
+		Primary      string
+		PrimaryError string `json:",omitempty"`
+		Shadow       string
+		ShadowError  string `json:",omitempty"`
+	}
+
+	// rollout represents request sampling and shadow processing state
+	rollout struct {
+		sampling    *Sampling
+		passThrough *Writer
+		shadow      Processor
+		shadowCtx   context.Context
+		sandbox     Reporter //shadow processor reporter, its side effects are discarded
+		report      *Writer
+		sourceURL   string
+	}
+)
+
+// sampled returns true if record is sampled for processing
+func (s *Sampling) sampled(data []byte) bool {
+	if s.Rate >= 1 {
+		return true
+	}
+	if s.Rate <= 0 {
+		return false
+	}
+	key := data
+	if len(s.By) > 0 {
+		key = []byte((&Partition{Spec: s.Spec, By: s.By}).Key(data))
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write(key)
+	value := hash.Sum64()
+	value ^= value >> 33 //fnv high bits are poorly distributed for short keys
+	value *= 0xff51afd7ed558ccd
+	value ^= value >> 33
+	return float64(value)/math.MaxUint64 < s.Rate
+}
+
+// sample returns sampled data with its record and passes the remaining records through,
+// batched records are sampled individually, sampled subset of the batch is returned with a new record
+func (r *rollout) sample(ctx context.Context, data interface{}, rec *record, response *Response) (interface{}, *record, error) {
+	if r == nil || r.sampling == nil {
+		return data, rec, nil
+	}
+	bs, ok := rec.bytes(data)
+	if !ok {
+		var err error
+		if bs, err = gojay.Marshal(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal data %+v due to %v", data, err)
+		}
+	}
+	if _, isBytes := data.([]byte); !isBytes || rec.count <= 1 {
+		if r.sampling.sampled(bs) {
+			atomic.AddInt32(&response.Sampled, 1)
+			return data, rec, nil
+		}
+		atomic.AddInt32(&response.PassedThrough, 1)
+		return nil, nil, r.passThrough.Write(ctx, bs)
+	}
+	var sampled [][]byte
+	result := &record{}
+	for i, line := range bytes.Split(bs, []byte{'\n'}) {
+		if r.sampling.sampled(line) {
+			sampled = append(sampled, line)
+			result.lines = append(result.lines, rec.lineAt(i))
+			if i < len(rec.attempts) {
+				result.attempts = append(result.attempts, rec.attempts[i])
+			}
+			atomic.AddInt32(&response.Sampled, 1)
+			continue
+		}
+		atomic.AddInt32(&response.PassedThrough, 1)
+		if err := r.passThrough.Write(ctx, line); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(sampled) == 0 {
+		return nil, nil, nil
+	}
+	if len(sampled) == rec.count {
+		return data, rec, nil
+	}
+	result.data = bytes.Join(sampled, []byte{'\n'})
+	result.line = result.lines[0]
+	result.count = len(sampled)
+	return result.data, result, nil
+}
+
+// copy returns data copy passed to the shadow processor, decoded rows are deep copied
+func (r *rollout) copy(data interface{}) interface{} {
+	if r == nil || r.shadow == nil || data == nil {
+		return nil
+	}
+	if bs, ok := data.([]byte); ok {
+		return append([]byte{}, bs...)
+	}
+	return deepCopy(reflect.ValueOf(data)).Interface()
+}
+
+// deepCopy returns value copy not sharing pointers, slices and maps with the value, unexported struct fields are copied as is
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.New(value.Type().Elem())
+		result.Elem().Set(deepCopy(value.Elem()))
+		return result
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.New(value.Type()).Elem()
+		result.Set(deepCopy(value.Elem()))
+		return result
+	case reflect.Struct:
+		result := reflect.New(value.Type()).Elem()
+		result.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if field := result.Field(i); field.CanSet() {
+				field.Set(deepCopy(value.Field(i)))
+			}
+		}
+		return result
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return result
+	case reflect.Array:
+		result := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return result
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return result
+	}
+	return value
+}
+
+// compare runs shadow processor and reports disagreement with the primary processor result
+func (s *Service) compare(ctx context.Context, r *rollout, data interface{}, rec *record, primaryErr error, response *Response, timeout chan bool) {
+	completed, shadowErr := s.processRecord(r.shadowCtx, r.shadow, data, r.sandbox, timeout)
+	if !completed {
+		shadowErr = context.DeadlineExceeded
+	}
+	atomic.AddInt32(&response.ShadowProcessed, 1)
+	if (primaryErr == nil) == (shadowErr == nil) {
+		return
+	}
+	atomic.AddInt32(&response.ShadowMismatches, 1)
+	result := &ShadowResult{SourceURL: r.sourceURL, Line: rec.line, Primary: outcome(primaryErr), Shadow: outcome(shadowErr)}
+	if bs, ok := rec.bytes(data); ok {
+		result.Record = string(bs)
+	} else if bs, err := gojay.Marshal(data); err == nil {
+		result.Record = string(bs)
+	}
+	if primaryErr != nil {
+		result.PrimaryError = primaryErr.Error()
+	}
+	if shadowErr != nil {
+		result.ShadowError = shadowErr.Error()
+	}
+	line, err := json.Marshal(result)
+	if err == nil {
+		err = r.report.Write(ctx, line)
+	}
+	response.LogError(err)
+}
+
+// outcome returns processing outcome class
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return StatusOk
+	case isDataCorruptionError(err):
+		return "corruption"
+	}
+	return StatusError
+}
+
+// newRollout creates request sampling and shadow processing state
+func (s *Service) newRollout(ctx context.Context, request *Request, response *Response) (*rollout, error) {
+	if s.Config.Sampling == nil && s.shadow == nil {
+		return nil, nil
+	}
+	result := &rollout{sampling: s.Config.Sampling, shadow: s.shadow, sourceURL: request.SourceURL}
+	if sampling := s.Config.Sampling; sampling != nil && sampling.PassThroughURL != "" {
+		response.PassThroughURL = expandURL(request.TransformSourceURL(sampling.PassThroughURL), request.StartTime)
+		result.passThrough = NewWriter(response.PassThroughURL, s.fs)
+		result.passThrough.header = func() []byte {
+			return response.header
+		}
+	}
+	if s.shadow == nil {
+		return result, nil
+	}
+	if shadow := s.Config.Shadow; shadow != nil && shadow.ReportURL != "" {
+		response.ShadowReportURL = expandURL(request.TransformSourceURL(shadow.ReportURL), request.StartTime)
+		result.report = NewWriter(response.ShadowReportURL, s.fs)
+	}
+	result.sandbox = s.reporterProvider()
+	result.shadowCtx = ctx
+	if preProcess, ok := s.shadow.(PreProcessor); ok {
+		var err error
+		if result.shadowCtx, err = preProcess.Pre(ctx, result.sandbox); err != nil {
+			return nil, fmt.Errorf("failed to pre process shadow: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// close runs shadow post processing and closes rollout writers
+func (r *rollout) close(response *Response) {
+	if r == nil {
+		return
+	}
+	if postProcess, ok := r.shadow.(PostProcessor); ok {
+		_ = postProcess.Post(r.shadowCtx, r.sandbox) //shadow side effects are discarded
+	}
+	if r.passThrough != nil {
+		response.LogError(r.passThrough.Close())
+	}
+	if r.report != nil {
+		response.LogError(r.report.Close())
+	}
+}
+
+// SetShadow sets shadow processor, it is called with a copy of every processed record,
+// its results are compared with the primary processor results
+func (s *Service) SetShadow(shadow Processor) {
+	s.shadow = shadow
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSampling_Sampled(t *testing.T) {
+	var useCases = []struct {
+		description string
+		sampling    *Sampling
+		min, max    int
+	}{
+		{description: "none", sampling: &Sampling{Rate: 0}, min: 0, max: 0},
+		{description: "all", sampling: &Sampling{Rate: 1}, min: 10000, max: 10000},
+		{description: "fraction", sampling: &Sampling{Rate: 0.3}, min: 2700, max: 3300},
+		{description: "key fraction", sampling: &Sampling{Rate: 0.5, Spec: Spec{Format: "csv"}, By: []Field{{Index: 1}}}, min: 4500, max: 5500},
+	}
+	for _, useCase := range useCases {
+		count := 0
+		for i := 0; i < 10000; i++ {
+			record := []byte(strconv.Itoa(i) + ",k" + strconv.Itoa(i%1000))
+			sampled := useCase.sampling.sampled(record)
+			assert.Equal(t, sampled, useCase.sampling.sampled(record), useCase.description)
+			if sampled {
+				count++
+			}
+		}
+		assert.True(t, count >= useCase.min && count <= useCase.max, useCase.description+" "+strconv.Itoa(count))
+	}
+}
+
+func TestService_Do_Sampling(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	var useCases = []struct {
+		description string
+		batchSize   int
+	}{
+		{description: "records"},
+		{description: "batched records", batchSize: 10},
+	}
+	fs := afs.New()
+	ctx := context.Background()
+	for _, useCase := range useCases {
+		var mux sync.Mutex
+		processed := map[int]bool{}
+		processor := NewTyped(func(ctx context.Context, value *int, reporter Reporter) error {
+			mux.Lock()
+			defer mux.Unlock()
+			processed[*value] = true
+			return nil
+		})
+		srv := New(&Config{
+			Concurrency:   4,
+			MaxExecTimeMs: 2000,
+			MaxRetries:    3,
+			BatchSize:     useCase.batchSize,
+			Sampling:      &Sampling{Rate: 0.25, PassThroughURL: "mem://localhost/sampling/pass"},
+		}, fs, processor, NewReporter)
+		response := srv.Do(ctx, NewRequest(strings.NewReader(strings.Join(lines, "\n")), nil, "mem://localhost/data/sampled.csv")).BaseResponse()
+		assert.EqualValues(t, 1000, response.Sampled+response.PassedThrough, useCase.description)
+		assert.EqualValues(t, len(processed), response.Sampled, useCase.description)
+		assert.True(t, response.Sampled > 150 && response.Sampled < 350, useCase.description+" "+strconv.Itoa(int(response.Sampled)))
+		passed, err := fs.DownloadWithURL(ctx, response.PassThroughURL)
+		if assert.Nil(t, err, useCase.description) {
+			passedLines := strings.Split(string(passed), "\n")
+			assert.EqualValues(t, response.PassedThrough, len(passedLines), useCase.description)
+			for _, line := range passedLines {
+				value, _ := strconv.Atoi(line)
+				assert.False(t, processed[value], useCase.description)
+			}
+		}
+	}
+}
+
+func TestRollout_Sample(t *testing.T) {
+	response := &Response{}
+	state := &rollout{sampling: &Sampling{Rate: 0.5}, passThrough: NewWriter("mem://localhost/sampling/batch/pass", afs.New())}
+	var lines []string
+	var attempts []int
+	for i := 0; i < 10; i++ {
+		lines = append(lines, strconv.Itoa(i))
+		attempts = append(attempts, i)
+	}
+	rec := &record{data: []byte(strings.Join(lines, "\n")), line: 20, count: 10, attempts: attempts}
+	data, sampled, err := state.sample(context.Background(), rec.data, rec, response)
+	assert.Nil(t, err)
+	assert.Nil(t, state.passThrough.Close())
+	if !assert.NotNil(t, sampled) {
+		return
+	}
+	assert.EqualValues(t, sampled.count, response.Sampled)
+	assert.Equal(t, data, sampled.data)
+	values := strings.Split(string(data.([]byte)), "\n")
+	assert.Equal(t, len(values), sampled.count)
+	assert.Equal(t, len(values), len(sampled.attempts))
+	for i, value := range values {
+		index, _ := strconv.Atoi(value)
+		assert.Equal(t, 20+index, sampled.lineAt(i))
+		assert.Equal(t, index, sampled.attempts[i])
+	}
+	assert.Equal(t, sampled.lineAt(0), sampled.line)
+
+	assert.NotNil(t, (&Config{Sampling: &Sampling{Rate: 0.5}}).Init(context.Background(), afs.New()))
+}
+
+type shadowRow struct {
+	ID     int
+	Tags   []string
+	Attrs  map[string]int
+	Parent *shadowRow
+}
+
+func TestRollout_Copy(t *testing.T) {
+	state := &rollout{shadow: &shadowCounter{}}
+	row := &shadowRow{ID: 1, Tags: []string{"a"}, Attrs: map[string]int{"x": 1}, Parent: &shadowRow{ID: 2}}
+	copied, ok := state.copy(row).(*shadowRow)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, row, copied)
+	copied.ID = 10
+	copied.Tags[0] = "b"
+	copied.Attrs["x"] = 2
+	copied.Parent.ID = 20
+	assert.Equal(t, &shadowRow{ID: 1, Tags: []string{"a"}, Attrs: map[string]int{"x": 1}, Parent: &shadowRow{ID: 2}}, row)
+	assert.Nil(t, (&rollout{}).copy(row))
+}
+
+type shadowCounter struct {
+	calls int32
+}
+
+func (s *shadowCounter) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	atomic.AddInt32(&s.calls, 1)
+	atomic.AddInt32(&reporter.BaseResponse().Processed, 100) //sandbox reporter side effect
+	switch string(data.([]byte)) {
+	case "3":
+		return errors.New("shadow error")
+	case "4":
+		return NewDataCorruption("shadow corruption")
+	}
+	return nil
+}
+
+func TestService_Do_Shadow(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	primary := NewTyped(func(ctx context.Context, value *int, reporter Reporter) error {
+		if *value == 5 {
+			return errors.New("primary error")
+		}
+		return nil
+	})
+	shadow := &shadowCounter{}
+	srv := New(&Config{
+		Concurrency:   2,
+		MaxExecTimeMs: 2000,
+		MaxRetries:    3,
+		Shadow:        &Shadow{ReportURL: "mem://localhost/shadow/report.json"},
+	}, fs, primary, NewReporter)
+	srv.SetShadow(shadow)
+	response := srv.Do(ctx, NewRequest(strings.NewReader("1\n2\n3\n4\n5"), nil, "mem://localhost/data/shadow.csv")).BaseResponse()
+	assert.EqualValues(t, 4, response.Processed)
+	assert.EqualValues(t, 5, response.ShadowProcessed)
+	assert.EqualValues(t, 5, shadow.calls)
+	assert.EqualValues(t, 3, response.ShadowMismatches)
+
+	report, err := fs.DownloadWithURL(ctx, response.ShadowReportURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	results := map[string]*ShadowResult{}
+	for _, line := range strings.Split(string(report), "\n") {
+		result := &ShadowResult{}
+		assert.Nil(t, json.Unmarshal([]byte(line), result))
+		results[result.Record] = result
+	}
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, &ShadowResult{SourceURL: "mem://localhost/data/shadow.csv", Line: 2, Record: "3", Primary: StatusOk, Shadow: StatusError, ShadowError: "shadow error"}, results["3"])
+	assert.Equal(t, "corruption", results["4"].Shadow)
+	assert.Equal(t, StatusOk, results["5"].Shadow)
+	assert.Equal(t, "primary error", results["5"].PrimaryError)
+}
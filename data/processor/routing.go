@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/viant/tapper/config"
+	"github.com/viant/toolbox"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type (
+	// Routing represents content based routing of processed records to named destinations
+	Routing struct {
+		Spec         //record format, CSV or JSON (default)
+		Routes       []*Route
+		Rules        []*Rule //rules are evaluated in order, the first matching rule routes the record
+		DefaultRoute string  //optional route of records not matching any rule, otherwise these records are skipped
+	}
+
+	// Route represents named destination stream with its own codec and rotation
+	Route struct {
+		Name string
+		config.Stream
+	}
+
+	// Rule represents routing rule
+	Rule struct {
+		Route  string
+		Field  *Field   //optional CSV field index or JSON field name, if empty Match is applied to the raw record
+		Equals []string //optional field values
+		Match  string   //optional regular expression
+		expr   *regexp.Regexp
+	}
+)
+
+// Init compiles rules and checks that every referenced route is defined and every rule has a condition
+func (r *Routing) Init() error {
+	if strings.ToLower(r.Format) == "csv" && r.Delimiter == "" {
+		r.Delimiter = ","
+	}
+	routes := map[string]bool{}
+	for _, route := range r.Routes {
+		if route.Name == "" || route.URL == "" {
+			return fmt.Errorf("invalid route: %+v, name and URL are required", route)
+		}
+		routes[route.Name] = true
+	}
+	if r.DefaultRoute != "" && !routes[r.DefaultRoute] {
+		return fmt.Errorf("unknown default route: %v", r.DefaultRoute)
+	}
+	for _, rule := range r.Rules {
+		if !routes[rule.Route] {
+			return fmt.Errorf("unknown rule route: %v", rule.Route)
+		}
+		if rule.Match == "" {
+			if len(rule.Equals) == 0 { //rule would never match
+				return fmt.Errorf("invalid rule %v, Equals or Match is required", rule.Route)
+			}
+			continue
+		}
+		var err error
+		if rule.expr, err = regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("invalid rule %v match: %v, due to %w", rule.Route, rule.Match, err)
+		}
+	}
+	return nil
+}
+
+// Expand returns initialised routing copy with route URLs expanded for the supplied time
+func (r *Routing) Expand(startTime time.Time) (*Routing, error) {
+	result := *r
+	result.Routes = make([]*Route, len(r.Routes))
+	for i, route := range r.Routes {
+		expanded := *route
+		expanded.URL = expandURL(route.URL, startTime)
+		if route.Rotation != nil {
+			rotation := *route.Rotation
+			rotation.URL = expandURL(rotation.URL, startTime)
+			expanded.Rotation = &rotation
+		}
+		result.Routes[i] = &expanded
+	}
+	result.Rules = make([]*Rule, len(r.Rules))
+	for i, rule := range r.Rules {
+		copied := *rule
+		result.Rules[i] = &copied
+	}
+	return &result, result.Init()
+}
+
+// Lookup returns a route for the supplied name
+func (r *Routing) Lookup(name string) *Route {
+	for _, route := range r.Routes {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// Match returns route name of the first rule matching the record, default route otherwise
+func (r *Routing) Match(record []byte) string {
+	for _, rule := range r.Rules {
+		if rule.matches(record, &r.Spec) {
+			return rule.Route
+		}
+	}
+	return r.DefaultRoute
+}
+
+func (r *Rule) matches(record []byte, spec *Spec) bool {
+	value := record
+	if r.Field != nil {
+		value = []byte(toolbox.AsString(r.Field.Value(record, spec)))
+	}
+	if len(r.Equals) > 0 {
+		matched := false
+		for _, candidate := range r.Equals {
+			if matched = candidate == string(value); matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if r.expr != nil {
+		return r.expr.Match(value)
+	}
+	return len(r.Equals) > 0
+}
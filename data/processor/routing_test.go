@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouting_Match(t *testing.T) {
+	var useCases = []struct {
+		description string
+		routing     *Routing
+		record      string
+		expect      string
+	}{
+		{
+			description: "csv column",
+			routing: &Routing{Spec: Spec{Format: "csv"}, Routes: []*Route{{Name: "us"}, {Name: "eu"}},
+				Rules: []*Rule{{Route: "us", Field: &Field{Index: 1}, Equals: []string{"US", "CA"}}, {Route: "eu", Field: &Field{Index: 1}, Match: "^(DE|FR)$"}}},
+			record: "1,FR,10",
+			expect: "eu",
+		},
+		{
+			description: "json field",
+			routing: &Routing{Routes: []*Route{{Name: "us"}, {Name: "eu"}},
+				Rules: []*Rule{{Route: "us", Field: &Field{Name: "country"}, Equals: []string{"US"}}}},
+			record: `{"id":1,"country":"US"}`,
+			expect: "us",
+		},
+		{
+			description: "raw line regex",
+			routing: &Routing{Routes: []*Route{{Name: "errors"}},
+				Rules: []*Rule{{Route: "errors", Match: `"level":"error"`}}},
+			record: `{"level":"error","msg":"failed"}`,
+			expect: "errors",
+		},
+		{
+			description: "default route",
+			routing: &Routing{Spec: Spec{Format: "csv"}, Routes: []*Route{{Name: "us"}, {Name: "other"}}, DefaultRoute: "other",
+				Rules: []*Rule{{Route: "us", Field: &Field{Index: 1}, Equals: []string{"US"}}}},
+			record: "1,PL,10",
+			expect: "other",
+		},
+		{
+			description: "no route",
+			routing: &Routing{Spec: Spec{Format: "csv"}, Routes: []*Route{{Name: "us"}},
+				Rules: []*Rule{{Route: "us", Field: &Field{Index: 1}, Equals: []string{"US"}}}},
+			record: "1,PL,10",
+			expect: "",
+		},
+	}
+	for _, useCase := range useCases {
+		for _, route := range useCase.routing.Routes {
+			route.URL = "mem://localhost/routes/" + route.Name + ".json"
+		}
+		if !assert.Nil(t, useCase.routing.Init(), useCase.description) {
+			continue
+		}
+		assert.Equal(t, useCase.expect, useCase.routing.Match([]byte(useCase.record)), useCase.description)
+	}
+}
+
+func TestRouting_Init(t *testing.T) {
+	routing := &Routing{Routes: []*Route{{Name: "us"}}}
+	routing.Routes[0].URL = "mem://localhost/routes/us.json"
+	routing.Rules = []*Rule{{Route: "eu"}}
+	assert.NotNil(t, routing.Init())
+	routing.Rules = []*Rule{{Route: "us", Match: "("}}
+	assert.NotNil(t, routing.Init())
+	routing.Rules = []*Rule{{Route: "us", Field: &Field{Name: "country"}}}
+	assert.NotNil(t, routing.Init())
+	routing.Rules = []*Rule{{Route: "us", Field: &Field{Name: "country"}, Equals: []string{"us"}}}
+	assert.Nil(t, routing.Init())
+	routing.Rules = nil
+	routing.DefaultRoute = "other"
+	assert.NotNil(t, routing.Init())
+}
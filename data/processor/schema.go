@@ -0,0 +1,447 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema represents JSON Schema used to validate JSON records, the following keywords are supported:
+// type, enum, const, properties, required, additionalProperties, items, minItems, maxItems, uniqueItems,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength, pattern,
+// minProperties, maxProperties, allOf, anyOf, oneOf, not and local $ref (#/definitions, #/$defs)
+type Schema struct {
+	Type                 interface{}        `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                *json.RawMessage   `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MultipleOf           *float64           `json:"multipleOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	accept               *bool              //boolean schema
+	types                []string
+	pattern              *regexp.Regexp
+	ref                  *Schema
+}
+
+// UnmarshalJSON decodes schema object or boolean schema
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	if text := string(bytes.TrimSpace(data)); text == "true" || text == "false" {
+		accept := text == "true"
+		s.accept = &accept
+		return nil
+	}
+	type schema Schema
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// Validate validates JSON record, it returns DataCorruption error describing the first violation
+func (s *Schema) Validate(record []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return NewDataCorruption(fmt.Sprintf("invalid JSON record: %s, due to %v", record, err))
+	}
+	if violation := s.validate("$", value); violation != "" {
+		return NewDataCorruption(fmt.Sprintf("schema validation failed: %v, record: %s", violation, record))
+	}
+	return nil
+}
+
+// Init compiles patterns and resolves references
+func (s *Schema) Init() error {
+	return s.init(s)
+}
+
+func (s *Schema) init(root *Schema) error {
+	switch actual := s.Type.(type) {
+	case nil:
+	case string:
+		s.types = []string{actual}
+	case []interface{}:
+		for _, item := range actual {
+			s.types = append(s.types, fmt.Sprint(item))
+		}
+	default:
+		return fmt.Errorf("invalid schema type: %v", s.Type)
+	}
+	if s.Pattern != "" {
+		var err error
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("invalid schema pattern: %v, due to %w", s.Pattern, err)
+		}
+	}
+	if s.Ref != "" {
+		if s.ref = root.lookup(s.Ref); s.ref == nil {
+			return fmt.Errorf("unsupported schema reference: %v", s.Ref)
+		}
+	}
+	for _, child := range s.children() {
+		if err := child.init(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) children() []*Schema {
+	var result []*Schema
+	for _, child := range s.Properties {
+		result = append(result, child)
+	}
+	for _, child := range s.Definitions {
+		result = append(result, child)
+	}
+	for _, child := range s.Defs {
+		result = append(result, child)
+	}
+	result = append(result, s.AllOf...)
+	result = append(result, s.AnyOf...)
+	result = append(result, s.OneOf...)
+	for _, child := range []*Schema{s.AdditionalProperties, s.Items, s.Not} {
+		if child != nil {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// lookup resolves local JSON pointer reference
+func (s *Schema) lookup(ref string) *Schema {
+	if !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	result := s
+	segments := strings.Split(strings.Trim(ref[1:], "/"), "/")
+	for i := 0; i < len(segments) && segments[0] != ""; i += 2 {
+		if i+1 >= len(segments) {
+			return nil
+		}
+		name := strings.ReplaceAll(strings.ReplaceAll(segments[i+1], "~1", "/"), "~0", "~")
+		switch segments[i] {
+		case "definitions":
+			result = result.Definitions[name]
+		case "$defs":
+			result = result.Defs[name]
+		case "properties":
+			result = result.Properties[name]
+		default:
+			return nil
+		}
+		if result == nil {
+			return nil
+		}
+	}
+	return result
+}
+
+// validate returns the first violation or empty string
+func (s *Schema) validate(path string, value interface{}) string {
+	if s.accept != nil {
+		if !*s.accept {
+			return path + ": value is not allowed"
+		}
+		return ""
+	}
+	if s.ref != nil {
+		if violation := s.ref.validate(path, value); violation != "" {
+			return violation
+		}
+	}
+	if len(s.types) > 0 && !s.matchesType(value) {
+		return fmt.Sprintf("%v: expected %v, but had %v", path, strings.Join(s.types, " or "), jsonType(value))
+	}
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, candidate := range s.Enum {
+			if matched = jsonEqual(candidate, value); matched {
+				break
+			}
+		}
+		if !matched {
+			return fmt.Sprintf("%v: value %v is not one of %v", path, value, s.Enum)
+		}
+	}
+	if s.Const != nil {
+		var expect interface{}
+		_ = json.Unmarshal(*s.Const, &expect)
+		if !jsonEqual(expect, value) {
+			return fmt.Sprintf("%v: expected %s, but had %v", path, *s.Const, value)
+		}
+	}
+	var violation string
+	switch actual := value.(type) {
+	case json.Number:
+		violation = s.validateNumber(path, actual)
+	case string:
+		violation = s.validateString(path, actual)
+	case []interface{}:
+		violation = s.validateArray(path, actual)
+	case map[string]interface{}:
+		violation = s.validateObject(path, actual)
+	}
+	if violation != "" {
+		return violation
+	}
+	return s.validateComposition(path, value)
+}
+
+func (s *Schema) validateNumber(path string, value json.Number) string {
+	number, err := value.Float64()
+	if err != nil {
+		return fmt.Sprintf("%v: invalid number %v", path, value)
+	}
+	if s.Minimum != nil && number < *s.Minimum {
+		return fmt.Sprintf("%v: %v is less than minimum %v", path, value, *s.Minimum)
+	}
+	if s.Maximum != nil && number > *s.Maximum {
+		return fmt.Sprintf("%v: %v is greater than maximum %v", path, value, *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+		return fmt.Sprintf("%v: %v is not greater than exclusive minimum %v", path, value, *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && number >= *s.ExclusiveMaximum {
+		return fmt.Sprintf("%v: %v is not less than exclusive maximum %v", path, value, *s.ExclusiveMaximum)
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		if quotient := number / *s.MultipleOf; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return fmt.Sprintf("%v: %v is not multiple of %v", path, value, *s.MultipleOf)
+		}
+	}
+	return ""
+}
+
+func (s *Schema) validateString(path string, value string) string {
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		return fmt.Sprintf("%v: length %v is less than minLength %v", path, length, *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Sprintf("%v: length %v is greater than maxLength %v", path, length, *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		return fmt.Sprintf("%v: %q does not match pattern %v", path, value, s.Pattern)
+	}
+	return ""
+}
+
+func (s *Schema) validateArray(path string, value []interface{}) string {
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		return fmt.Sprintf("%v: %v items is less than minItems %v", path, len(value), *s.MinItems)
+	}
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		return fmt.Sprintf("%v: %v items is greater than maxItems %v", path, len(value), *s.MaxItems)
+	}
+	for i, item := range value {
+		if s.Items != nil {
+			if violation := s.Items.validate(path+"["+strconv.Itoa(i)+"]", item); violation != "" {
+				return violation
+			}
+		}
+		if !s.UniqueItems {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if jsonEqual(value[j], item) {
+				return fmt.Sprintf("%v: items %v and %v are not unique", path, j, i)
+			}
+		}
+	}
+	return ""
+}
+
+func (s *Schema) validateObject(path string, value map[string]interface{}) string {
+	if s.MinProperties != nil && len(value) < *s.MinProperties {
+		return fmt.Sprintf("%v: %v properties is less than minProperties %v", path, len(value), *s.MinProperties)
+	}
+	if s.MaxProperties != nil && len(value) > *s.MaxProperties {
+		return fmt.Sprintf("%v: %v properties is greater than maxProperties %v", path, len(value), *s.MaxProperties)
+	}
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			return fmt.Sprintf("%v: missing required property %v", path, name)
+		}
+	}
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names) //deterministic first violation
+	for _, name := range names {
+		item := value[name]
+		property, ok := s.Properties[name]
+		if !ok {
+			property = s.AdditionalProperties
+		}
+		if property == nil {
+			continue
+		}
+		if violation := property.validate(path+"."+name, item); violation != "" {
+			return violation
+		}
+	}
+	return ""
+}
+
+func (s *Schema) validateComposition(path string, value interface{}) string {
+	for _, schema := range s.AllOf {
+		if violation := schema.validate(path, value); violation != "" {
+			return violation
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		var violation string
+		for _, schema := range s.AnyOf {
+			if violation = schema.validate(path, value); violation == "" {
+				break
+			}
+		}
+		if violation != "" {
+			return fmt.Sprintf("%v: does not match any schema of anyOf (%v)", path, violation)
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, schema := range s.OneOf {
+			if schema.validate(path, value) == "" {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Sprintf("%v: matches %v schemas of oneOf, expected exactly one", path, matched)
+		}
+	}
+	if s.Not != nil && s.Not.validate(path, value) == "" {
+		return fmt.Sprintf("%v: must not match schema of not", path)
+	}
+	return ""
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := jsonType(value)
+	for _, expect := range s.types {
+		if expect == actual || (expect == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns JSON Schema type of the decoded value
+func jsonType(value interface{}) string {
+	switch actual := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if number, err := actual.Float64(); err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// jsonEqual compares decoded JSON values, numbers are compared by value
+func jsonEqual(expect, actual interface{}) bool {
+	expect, actual = normalizeJSON(expect), normalizeJSON(actual)
+	return reflect.DeepEqual(expect, actual)
+}
+
+func normalizeJSON(value interface{}) interface{} {
+	switch actual := value.(type) {
+	case json.Number:
+		number, _ := actual.Float64()
+		return number
+	case []interface{}:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = normalizeJSON(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(actual))
+		for key, item := range actual {
+			result[key] = normalizeJSON(item)
+		}
+		return result
+	}
+	return value
+}
+
+// loadSchema loads JSON Schema from Config.SchemaURL, non JSON sources are not validated
+func (s *Service) loadSchema(ctx context.Context, request *Request) (*Schema, error) {
+	if s.Config.SchemaURL == "" || !isJSONSource(request.SourceType) {
+		return nil, nil
+	}
+	s.schemaMux.Lock()
+	defer s.schemaMux.Unlock()
+	if s.schema != nil {
+		return s.schema, nil
+	}
+	data, err := s.fs.DownloadWithURL(ctx, s.Config.SchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema: %v, due to %w", s.Config.SchemaURL, err)
+	}
+	schema := &Schema{}
+	if err = json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %v, due to %w", s.Config.SchemaURL, err)
+	}
+	if err = schema.Init(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v, due to %w", s.Config.SchemaURL, err)
+	}
+	s.schema = schema
+	return schema, nil
+}
+
+// validateRecord validates JSON record with the schema, records decoded into a row type are validated with the source line
+func validateRecord(schema *Schema, data interface{}, rec *record) error {
+	if schema == nil {
+		return nil
+	}
+	record, ok := rec.bytes(data)
+	if !ok {
+		return nil
+	}
+	return schema.Validate(record)
+}
+
+// isJSONSource returns true if source type is decoded as JSON lines
+func isJSONSource(sourceType string) bool {
+	decoder, ok := LookupDecoder(sourceType).(*textDecoder)
+	return ok && decoder.json
+}
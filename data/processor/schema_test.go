@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/francoispqt/gojay"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/afs/file"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["id", "email"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"status": {"enum": ["active", "inactive"]},
+		"tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}, "uniqueItems": true},
+		"score": {"type": ["number", "null"], "exclusiveMaximum": 100}
+	},
+	"definitions": {
+		"tag": {"type": "string", "minLength": 2}
+	}
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	var useCases = []struct {
+		description string
+		record      string
+		expect      string
+	}{
+		{description: "valid", record: `{"id":1,"email":"a@b.com","status":"active","tags":["xx","yy"],"score":null}`},
+		{description: "valid integer float", record: `{"id":2.0,"email":"a@b.com","score":99.5}`},
+		{description: "missing required", record: `{"id":1}`, expect: "$: missing required property email"},
+		{description: "wrong type", record: `{"id":"1","email":"a@b.com"}`, expect: "$.id: expected integer, but had string"},
+		{description: "minimum", record: `{"id":0,"email":"a@b.com"}`, expect: "$.id: 0 is less than minimum 1"},
+		{description: "pattern", record: `{"id":1,"email":"abc"}`, expect: `$.email: "abc" does not match pattern`},
+		{description: "enum", record: `{"id":1,"email":"a@b.com","status":"deleted"}`, expect: "$.status: value deleted is not one of"},
+		{description: "ref", record: `{"id":1,"email":"a@b.com","tags":["x"]}`, expect: "$.tags[0]: length 1 is less than minLength 2"},
+		{description: "unique items", record: `{"id":1,"email":"a@b.com","tags":["xx","xx"]}`, expect: "$.tags: items 0 and 1 are not unique"},
+		{description: "exclusive maximum", record: `{"id":1,"email":"a@b.com","score":100}`, expect: "$.score: 100 is not less than exclusive maximum 100"},
+		{description: "additional property", record: `{"id":1,"email":"a@b.com","name":"x"}`, expect: "$.name: value is not allowed"},
+		{description: "invalid json", record: `{"id":1`, expect: "invalid JSON record"},
+	}
+	schema := &Schema{}
+	if !assert.Nil(t, json.Unmarshal([]byte(testSchema), schema)) || !assert.Nil(t, schema.Init()) {
+		return
+	}
+	for _, useCase := range useCases {
+		err := schema.Validate([]byte(useCase.record))
+		if useCase.expect == "" {
+			assert.Nil(t, err, useCase.description)
+			continue
+		}
+		if !assert.NotNil(t, err, useCase.description) {
+			continue
+		}
+		assert.True(t, isDataCorruptionError(err), useCase.description)
+		assert.Contains(t, err.Error(), useCase.expect, useCase.description)
+	}
+}
+
+func TestService_Do_Schema(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	schemaURL := "mem://localhost/schema/user.json"
+	assert.Nil(t, fs.Upload(ctx, schemaURL, file.DefaultFileOsMode, strings.NewReader(testSchema)))
+	input := `{"id":1,"email":"a@b.com"}` + "\n" + `{"id":2}` + "\n" + `{"id":3,"email":"c@d.com"}`
+	var useCases = []struct {
+		description     string
+		schemaURL       string
+		sourceURL       string
+		input           string
+		rowType         reflect.Type
+		expectProcessed int32
+		expectCorrupted string
+		expectStatus    string
+	}{
+		{description: "JSON lines", schemaURL: schemaURL, sourceURL: "mem://localhost/data/users.json", input: input, expectProcessed: 2, expectCorrupted: `{"id":2}`},
+		{description: "row type", schemaURL: schemaURL, sourceURL: "mem://localhost/data/typed/users.json", input: input, rowType: reflect.TypeOf(schemaUser{}), expectProcessed: 2, expectCorrupted: `{"id":2}`},
+		{description: "CSV is not validated", schemaURL: schemaURL, sourceURL: "mem://localhost/data/schema/users.csv", input: "1,a@b.com\n2", expectProcessed: 2},
+		{description: "missing schema", schemaURL: "mem://localhost/schema/missing.json", sourceURL: "mem://localhost/data/missing/users.json", input: input, expectStatus: StatusError},
+	}
+	for _, useCase := range useCases {
+		var processed int32
+		srv := New(&Config{
+			Concurrency:   2,
+			MaxExecTimeMs: 2000,
+			MaxRetries:    3,
+			SchemaURL:     useCase.schemaURL,
+			CorruptionURL: "mem://localhost/corruption",
+		}, fs, ProcessFunc(func(ctx context.Context, data interface{}, reporter Reporter) error {
+			atomic.AddInt32(&processed, 1)
+			return nil
+		}), NewReporter)
+		request := NewRequest(strings.NewReader(useCase.input), nil, useCase.sourceURL)
+		request.SourceType = ""
+		request.RowType = useCase.rowType
+		response := srv.Do(ctx, request).BaseResponse()
+		if useCase.expectStatus != "" {
+			assert.Equal(t, useCase.expectStatus, response.Status, useCase.description)
+			continue
+		}
+		assert.EqualValues(t, useCase.expectProcessed, processed, useCase.description)
+		data, err := fs.DownloadWithURL(ctx, response.CorruptionURL)
+		if useCase.expectCorrupted == "" {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		assert.Nil(t, err, useCase.description)
+		assert.Equal(t, useCase.expectCorrupted, string(data), useCase.description)
+	}
+
+	cfg := &Config{SchemaURL: schemaURL, SourceType: CSV}
+	assert.NotNil(t, cfg.Init(ctx, fs))
+}
+
+type schemaUser struct {
+	ID    int
+	Email string
+}
+
+func (u *schemaUser) UnmarshalJSONObject(dec *gojay.Decoder, key string) error {
+	switch key {
+	case "id":
+		return dec.Int(&u.ID)
+	case "email":
+		return dec.String(&u.Email)
+	}
+	return nil
+}
+
+func (u *schemaUser) NKeys() int {
+	return 2
+}
+
+type jsonProcessor struct {
+	processor *orderProcessor
+}
+
+func (p *jsonProcessor) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	p.processor.mux.Lock()
+	defer p.processor.mux.Unlock()
+	p.processor.values["id"] = append(p.processor.values["id"], string(data.([]byte)))
+	return nil
+}
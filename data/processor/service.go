@@ -1,12 +1,9 @@
 package processor
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/francoispqt/gojay"
-	"github.com/vc42/parquet-go"
 	"github.com/viant/afs"
 	"github.com/viant/afs/file"
 	"github.com/viant/afs/storage"
@@ -32,6 +29,14 @@ type Service struct {
 	fs      afs.Service
 	Processor
 	reporterProvider func() Reporter
+	limiter          *rateLimiter
+	limiterOnce      sync.Once
+	breaker          *circuitBreaker
+	breakerOnce      sync.Once
+	schema           *Schema
+	schemaMux        sync.Mutex
+	shadow           Processor
+	interceptors     []Interceptor
 }
 
 // Do starts service processing
@@ -52,6 +57,14 @@ func (s *Service) Do(ctx context.Context, request *Request) Reporter {
 	}
 	response.SourceURL = request.SourceURL
 	response.StartTime = request.StartTime
+	if notBefore := request.NotBefore(); time.Now().Before(notBefore) { //retry is not due yet
+		response.Status = StatusDeferred
+		response.NotBefore = &notBefore
+		if request.ReadCloser != nil {
+			_ = request.ReadCloser.Close()
+		}
+		return reporter
+	}
 	var err error
 	err = s.onMirror(context.Background(), request)
 	if err != nil {
@@ -67,21 +80,53 @@ func (s *Service) Do(ctx context.Context, request *Request) Reporter {
 		}
 	}
 
-	if request.SourceType == Parquet {
-		err = s.do(ctx, request, reporter, s.loadParquetData)
-	} else { // CSV and JSON
-		err = s.do(ctx, request, reporter, s.loadData)
+	if request.SourceType == "" {
+		request.SourceType = s.Config.SourceTypeOf(request.SourceURL)
+	}
+	if request.RowType == nil {
+		request.RowType = s.rowType(request.SourceType)
 	}
+	completionCtx, cancel := s.completionContext(ctx)
+	defer cancel()
+	defer s.publishCompletion(completionCtx, reporter) //published once the source is done or moved
+	err = s.do(ctx, request, reporter, s.loadData)
 	if err != nil {
 		response.LogError(err)
 	}
 	if err == nil {
+		if response.CheckpointURL != "" { //source is kept to resume processing from the checkpoint
+			if request.ReadCloser != nil {
+				_ = request.ReadCloser.Close()
+			}
+			return reporter
+		}
 		err := s.onDone(context.Background(), request)
 		response.LogError(err)
 	}
 	return reporter
 }
 
+// rowType returns processor row type for sources decoded into typed records, i.e. parquet or CSV with header
+func (s *Service) rowType(sourceType string) reflect.Type {
+	typer, ok := s.Processor.(RowTyper)
+	if !ok {
+		return nil
+	}
+	switch decoder := LookupDecoder(sourceType).(type) {
+	case BinaryDecoder:
+		if !decoder.RandomAccess() { //avro records are decoded with gojay
+			return nil
+		}
+	case *textDecoder:
+		if decoder.json {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return typer.RowType()
+}
+
 func (s *Service) handleQuorumFlow(ctx context.Context, request *Request, response *Response) (bool, error) {
 	ext := path.Ext(request.SourceURL)
 	hasQuorum := strings.Contains(ext, s.Config.QuorumExt)
@@ -89,6 +134,9 @@ func (s *Service) handleQuorumFlow(ctx context.Context, request *Request, respon
 		response.Status = "QuorumSkipped"
 		return true, nil
 	}
+	if s.Config.QuorumManifest != nil {
+		return s.handleManifestQuorum(ctx, request, response)
+	}
 	if request.ReadCloser != nil {
 		request.ReadCloser.Close()
 	}
@@ -155,13 +203,35 @@ func (s *Service) mergeFile(ctx context.Context, object storage.Object, writer i
 }
 
 func (s *Service) do(ctx context.Context, request *Request, reporter Reporter,
-	load func(ctx context.Context, waitGroup *sync.WaitGroup, request *Request, stream chan interface{}, response *Response, retryWriter *Writer)) (err error) {
+	load func(ctx context.Context, waitGroup *sync.WaitGroup, request *Request, stream chan *record, response *Response, retryWriter *Writer, progress *progress)) (err error) {
 	response := reporter.BaseResponse()
-	s.makeURL(response, request)
+	s.makeURL(ctx, response, request)
 	defer func() {
 		response.RuntimeMs = int(time.Since(request.StartTime).Milliseconds())
 	}()
-	retryWriter, corruptionWriter := s.openWriters(response.RetryURL, response.CorruptionURL)
+	if s.Config.Routing != nil {
+		if response.Routing, err = s.Config.Routing.Expand(request.StartTime); err != nil {
+			return err
+		}
+	}
+	progress, err := s.loadProgress(ctx, request)
+	if err != nil {
+		return err
+	}
+	schema, err := s.loadSchema(ctx, request)
+	if err != nil {
+		return err
+	}
+	retryWriter, corruptionWriter := s.openWriters(response, s.newEnvelope(request))
+	rollout, err := s.newRollout(ctx, request, response)
+	if err != nil {
+		return err
+	}
+	defer rollout.close(response)
+	ctx = context.WithValue(ctx, retryKey, &retryDestination{writer: retryWriter, deadline: s.Config.Deadline(ctx)})
+	if ctx, err = s.preIntercept(ctx, reporter); err != nil {
+		return err
+	}
 	if preProcess, ok := s.Processor.(PreProcessor); ok {
 		if ctx, err = preProcess.Pre(ctx, reporter); err != nil {
 			return err
@@ -170,159 +240,255 @@ func (s *Service) do(ctx context.Context, request *Request, reporter Reporter,
 	if s.Config.Concurrency == 0 {
 		s.Config.Concurrency = 1
 	}
+	workers, concurrency := s.workers()
 	waitGroup := &sync.WaitGroup{}
-	consumers := s.Config.Concurrency + 1
+	consumers := workers + 1
 	waitGroup.Add(consumers)
 
 	streamSize := 10*s.Config.Concurrency + 1
-	stream := make(chan interface{}, streamSize)
+	if s.Config.MemoryBudgetMB > 0 { //in-flight records are bounded by the budget
+		streamSize = 1000*s.Config.Concurrency + 1
+	}
+	stream := make(chan *record, streamSize)
+	budget := newInFlightBudget(s.Config.MemoryBudgetMB)
+	ctx = context.WithValue(ctx, budgetKey, budget)
 
 	defer s.closeWriters(response, retryWriter, corruptionWriter)
-	go load(ctx, waitGroup, request, stream, response, retryWriter)
+	go load(ctx, waitGroup, request, stream, response, retryWriter, progress)
 	var timeout = make(chan bool)
 
 	go s.setTimeoutChannel(ctx, timeout)
-	for i := 0; i < s.Config.Concurrency; i++ {
-		go s.runWorker(ctx, waitGroup, stream, reporter, retryWriter, corruptionWriter, timeout)
+	streams := s.partition(stream, workers, response)
+	for i := 0; i < workers; i++ {
+		go s.runWorker(ctx, waitGroup, streams[i], reporter, retryWriter, corruptionWriter, timeout, progress, concurrency, schema, rollout)
 	}
 	waitGroup.Wait()
+	response.PeakInFlightBytes = budget.peakInFlight()
+	if concurrency != nil {
+		response.PeakConcurrency = int32(concurrency.peakConcurrency())
+	}
 
 	if postProcess, ok := s.Processor.(PostProcessor); ok {
 		if err = postProcess.Post(ctx, reporter); err != nil {
 			return err
 		}
 	}
-	return nil
-}
-
-func (s *Service) loadParquetData(ctx context.Context, waitGroup *sync.WaitGroup, request *Request, stream chan interface{}, response *Response, retryWriter *Writer) {
-	defer waitGroup.Done()
-	defer close(stream)
-	deadline := s.Config.LoaderDeadline(ctx)
-	parReader := parquet.NewReader(request.ReaderAt)
-	defer parReader.Close()
-
-	for {
-		rowPtr := reflect.New(request.RowType).Interface()
-		err := parReader.Read(rowPtr)
-		if err != nil {
-			if err != io.EOF {
-				response.LogError(err)
-			}
-			break
-		}
-		if time.Now().After(deadline) {
-			data, err := gojay.Marshal(rowPtr)
-			if err != nil {
-				response.LogError(err)
-			} else {
-				s.writeToRetry(retryWriter, data, response)
-			}
-			response.LoadTimeouts++
-			continue
-		}
-		response.Loaded++
-		stream <- rowPtr
+	if err = s.postIntercept(ctx, reporter); err != nil {
+		return err
 	}
+	return s.saveProgress(context.Background(), request, progress, response)
 }
 
-func (s *Service) loadData(ctx context.Context, waitGroup *sync.WaitGroup, request *Request, stream chan interface{}, response *Response, retryWriter *Writer) {
+func (s *Service) loadData(ctx context.Context, waitGroup *sync.WaitGroup, request *Request, stream chan *record, response *Response, retryWriter *Writer, progress *progress) {
+	budget := budgetOf(ctx)
 	defer waitGroup.Done()
 	defer close(stream)
-	var reader io.Reader = request.ReadCloser
-	if len(s.Config.Sort.By) > 0 {
-		var err error
-		if reader, err = s.sortInput(reader, response); err != nil {
-			response.LogError(err)
-		}
+	reader, err := s.newRecordReader(ctx, request)
+	if err != nil {
+		response.LogError(err)
+		return
 	}
-	deadline := s.Config.LoaderDeadline(ctx)
-	scanner := bufio.NewScanner(reader)
-	s.Config.AdjustScannerBuffer(scanner)
-
 	defer func() {
-		if scanner.Err() != io.EOF {
-			response.LogError(scanner.Err())
-		}
+		response.LogError(reader.Close())
 	}()
-
-	if request.SourceType == CSV && s.Config.Sort.Batch && len(s.Config.Sort.By) > 0 {
-		s.loadInGroups(ctx, scanner, deadline, retryWriter, response, stream)
+	source, err := newSource(reader, request.Retry(), progress, response)
+	if err != nil {
+		response.LogError(err)
 		return
 	}
-	if request.SourceType == CSV && s.Config.BatchSize > 0 {
-		s.loadInBatches(ctx, s.Config.BatchSize, scanner, deadline, retryWriter, response, stream)
-		return
+	if headerReader, ok := reader.(HeaderReader); ok { //set before streaming records, retry and corruption files start with the source header
+		response.header = headerReader.HeaderLine()
+		response.columns = headerReader.Header()
 	}
-
-	for scanner.Scan() {
-		bs := scanner.Bytes()
-		data := make([]byte, len(bs))
-		copy(data, bs)
-		if time.Now().After(deadline) {
-			s.writeToRetry(retryWriter, data, response)
-			response.LoadTimeouts++
+	deadline := s.Config.LoaderDeadline(ctx)
+	if delimited, ok := reader.(DelimitedReader); ok && delimited.Delimiter() != "" {
+		if s.Config.Sort.Batch && len(s.Config.Sort.By) > 0 {
+			s.loadInGroups(ctx, source, deadline, retryWriter, response, stream)
+			return
+		}
+		if s.Config.BatchSize > 0 && !s.Config.Partition.Enabled() { //partitioned records are not batched
+			s.loadInBatches(ctx, s.Config.BatchSize, source, deadline, retryWriter, response, stream)
+			return
+		}
+	}
+	for {
+		rec := source.next()
+		if rec == nil {
+			return
+		}
+		if rec.corrupt != nil { //decode failures are written to the corruption destination by the worker
+			budget.send(stream, rec)
 			continue
 		}
-		if request.SourceType == JSON && request.RowType != nil {
-			rowPtr := reflect.New(request.RowType).Interface()
-			if err := gojay.Unmarshal(data, rowPtr); err != nil {
-				response.LogError(err)
-				continue
+		if time.Now().After(deadline) {
+			response.LoadTimeouts++
+			if progress != nil { //remaining records are processed from the checkpoint
+				progress.truncate(rec.line)
+				return
 			}
-			stream <- rowPtr
-		} else {
-			stream <- data
+			s.retryWriter(rec.data, rec, errNotProcessed, retryWriter, response)
+			continue
 		}
+		budget.send(stream, rec)
 		response.Loaded++
 	}
 }
 
-func (s *Service) runWorker(ctx context.Context, wg *sync.WaitGroup, stream chan interface{}, reporter Reporter, retryWriter *Writer, corruptionWriter *Writer, timeout chan bool) {
+func (s *Service) newRecordReader(ctx context.Context, request *Request) (RecordReader, error) {
+	decoder := LookupDecoder(request.SourceType)
+	if decoder == nil {
+		return nil, fmt.Errorf("unsupported source type: %v", request.SourceType)
+	}
+	return decoder.NewReader(ctx, request, s.Config)
+}
+
+func (s *Service) runWorker(ctx context.Context, wg *sync.WaitGroup, stream chan *record, reporter Reporter, retryWriter *Writer, corruptionWriter *Writer, timeout chan bool, progress *progress, concurrency *concurrencyLimiter, schema *Schema, rollout *rollout) {
 	response := reporter.BaseResponse()
 	defer wg.Done()
 	deadline := s.Config.Deadline(ctx)
-	for data := range stream {
-		if time.Now().After(deadline) {
-			s.retryWriter2(ctx, data, retryWriter, response)
-			continue
-		}
-		var done = make(chan bool)
-		go func() {
-			err := s.Process(ctx, data, reporter)
+	breaker := s.circuitBreaker()
+	budget := budgetOf(ctx)
+	for rec := range stream {
+		func(rec *record) {
+			data := rec.data
+			loaded := rec //source records completed and released to the budget by the worker, sampled record may have only a subset of them
+			defer budget.release(loaded)
+			if rec.corrupt != nil {
+				//records failed to decode are not passed to the Processor
+				response.LogError(rec.corrupt)
+				s.corruptionWriter(data, rec, rec.corrupt, corruptionWriter, response)
+				progress.complete(rec.line, rec.count)
+				return
+			}
+			if time.Now().After(deadline) {
+				if progress == nil {
+					s.retryWriter2(ctx, data, rec, errNotProcessed, retryWriter, response)
+				}
+				return
+			}
+			var err error
+			if data, rec, err = rollout.sample(ctx, data, loaded, response); err != nil || data == nil { //records not sampled are passed through
+				response.LogError(err)
+				progress.complete(loaded.line, loaded.count)
+				return
+			}
+			if err := validateRecord(schema, data, rec); err != nil { //invalid records are not passed to the Processor
+				response.LogError(err)
+				s.corruptionWriter(data, rec, err, corruptionWriter, response)
+				progress.complete(loaded.line, loaded.count)
+				return
+			}
+			if !breaker.allow() {
+				response.tripCircuit()
+				s.retryWriter(data, rec, errCircuitOpen, retryWriter, response)
+				progress.complete(loaded.line, loaded.count)
+				return
+			}
+			acquired := concurrency.acquire(deadline) //slot is taken first, so that rate limit tokens are only spent by Process calls
+			if !acquired || !s.rateLimit(ctx, rec, deadline, response) {
+				if acquired {
+					concurrency.cancel()
+				}
+				breaker.cancel()
+				if progress == nil {
+					s.retryWriter2(ctx, data, rec, errNotProcessed, retryWriter, response)
+				}
+				return
+			}
+			started := time.Now()
+			shadowData := rollout.copy(data)
+			completed, err := s.processRecord(ctx, s.intercepted(), data, reporter, timeout)
+			if !completed {
+				concurrency.release(time.Since(started), true)
+				breaker.record(true)
+				response.LogError(newProcessError(fmt.Sprintf("deadline exceeded while processing %+v", data)))
+				if progress != nil && time.Now().After(deadline) { //remaining records are processed from the checkpoint
+					return
+				}
+				s.retryWriter(data, rec, context.DeadlineExceeded, retryWriter, response) //record timeout is retried as any other failure
+				progress.complete(loaded.line, loaded.count)
+				return
+			}
+			failed := err != nil && !isDataCorruptionError(err)
+			concurrency.release(time.Since(started), failed)
+			breaker.record(failed)
+			if shadowData != nil { //shadow processing does not hold primary concurrency
+				s.compare(ctx, rollout, shadowData, rec, err, response, timeout)
+			}
 			if err != nil {
 				switch actual := err.(type) {
 				case *DataCorruption:
 					response.LogError(err)
-					s.corruptionWriter(data, corruptionWriter, response)
+					s.corruptionWriter(data, rec, err, corruptionWriter, response)
 				case *PartialRetry:
-					s.partialRetryWriter(actual, data, response, retryWriter)
+					if actual.corrupt != nil { //corrupted part is not retried
+						corruption := NewDataCorruption(actual.message)
+						response.LogError(corruption)
+						s.corruptionWriter(actual.corrupt, rec.subset(actual.corrupt), corruption, corruptionWriter, response)
+						if actual.data == nil { //nothing left to retry
+							atomic.AddInt32(&response.Processed, 1)
+							break
+						}
+					}
+					s.partialRetryWriter(actual, data, rec, response, retryWriter)
 					response.LogError(newProcessError(fmt.Sprintf("failed to process data due to %+v,  %+v", actual, data)))
 				default:
 					response.LogError(newProcessError(fmt.Sprintf(" failed to process data due to %v, %+v", err, data)))
-					s.retryWriter(data, retryWriter, response)
+					s.retryWriter(data, rec, err, retryWriter, response)
 				}
 			} else {
 				atomic.AddInt32(&response.Processed, 1)
 			}
-			done <- true
-			close(done)
-		}()
+			progress.complete(loaded.line, loaded.count)
+		}(rec)
+	}
+}
 
-		select {
-		case <-done:
-		case <-timeout:
-			response.LogError(newProcessError(fmt.Sprintf("deadline exceeded while processing %+v", data)))
-			s.retryWriter(data, retryWriter, response)
-		}
+// processRecord calls Process with a record context cancelled on record timeout or deadline,
+// it returns false if Process has not completed in time
+func (s *Service) processRecord(ctx context.Context, processor Processor, data interface{}, reporter Reporter, timeout chan bool) (bool, error) {
+	recordCtx, cancel := s.recordContext(ctx)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if cause := recover(); cause != nil { //one poison record does not take down the whole request
+				result <- s.recovered(cause, reporter)
+			}
+		}()
+		result <- processor.Process(recordCtx, data, reporter)
+	}()
+	select {
+	case err := <-result:
+		return true, err
+	case <-recordCtx.Done():
+	case <-timeout:
+	}
+	select {
+	case err := <-result: //completed along with timeout
+		return true, err
+	default:
+	}
+	cancel()
+	select {
+	case <-result:
+	case <-time.After(cancellationGrace): //Process ignores cancellation
+		atomic.AddInt32(&reporter.BaseResponse().ProcessLeaks, 1)
+	}
+	return false, nil
+}
 
+func (s *Service) recordContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.Config.RecordTimeoutMs > 0 {
+		return context.WithTimeout(ctx, time.Duration(s.Config.RecordTimeoutMs)*time.Millisecond)
 	}
+	return context.WithCancel(ctx)
 }
 
-func (s *Service) retryWriter2(ctx context.Context, data interface{}, retryWriter *Writer, response *Response) {
-	v, ok := data.([]byte)
+func (s *Service) retryWriter2(ctx context.Context, data interface{}, rec *record, cause error, retryWriter *Writer, response *Response) {
+	v, ok := rec.bytes(data)
 	if ok {
-		if err := retryWriter.Write(ctx, v); err != nil {
+		if err := retryWriter.writeRecord(ctx, v, rec, cause); err != nil {
 			response.LogError(newRetryError(fmt.Sprintf(" failed to write data %v due to %v", data, err)))
 		}
 	} else {
@@ -330,35 +496,39 @@ func (s *Service) retryWriter2(ctx context.Context, data interface{}, retryWrite
 		if err != nil {
 			response.LogError(fmt.Errorf(" failed to marshal data %+v due to %v", data, err))
 		} else {
-			if err = retryWriter.Write(ctx, vj); err != nil {
+			if err = retryWriter.writeRecord(ctx, vj, rec, cause); err != nil {
 				response.LogError(newRetryError(fmt.Sprintf(" failed to write data %v due to %v", vj, err)))
 			}
 		}
 	}
 }
 
-func (s *Service) retryWriter(data interface{}, retryWriter *Writer, response *Response) {
-	v, ok := data.([]byte)
+func (s *Service) retryWriter(data interface{}, rec *record, cause error, retryWriter *Writer, response *Response) {
+	v, ok := rec.bytes(data)
 	if ok {
-		s.writeToRetry(retryWriter, v, response)
+		s.writeToRetry(retryWriter, v, rec, cause, response)
 	} else {
 		vj, err := gojay.Marshal(data)
 		if err != nil {
 			response.LogError(fmt.Errorf(" failed to marshal data %+v due to %v", data, err))
 		} else {
-			s.writeToRetry(retryWriter, vj, response)
+			s.writeToRetry(retryWriter, vj, rec, cause, response)
 		}
 	}
 }
 
-func (s *Service) partialRetryWriter(actual *PartialRetry, data interface{}, response *Response, retryWriter *Writer) {
+func (s *Service) partialRetryWriter(actual *PartialRetry, data interface{}, rec *record, response *Response, retryWriter *Writer) {
 	v, ok := data.([]byte)
+	if !ok && actual.data == nil {
+		v, ok = rec.bytes(data)
+	}
 	if ok {
 		if actual.data != nil {
 			v = actual.data.([]byte)
+			rec = rec.subset(v) //retried lines keep their source record numbers and attempts
 			atomic.AddInt32(&response.Processed, 1)
 		}
-		s.writeToRetry(retryWriter, v, response)
+		s.writeToRetry(retryWriter, v, rec, actual, response)
 	} else {
 		if actual.data != nil {
 			data = actual.data
@@ -368,21 +538,21 @@ func (s *Service) partialRetryWriter(actual *PartialRetry, data interface{}, res
 			response.LogError(fmt.Errorf(" failed to marshal data %+v due to %v", data, err))
 		} else {
 			atomic.AddInt32(&response.Processed, 1)
-			s.writeToRetry(retryWriter, vj, response)
+			s.writeToRetry(retryWriter, vj, rec, actual, response)
 		}
 	}
 }
 
-func (s *Service) corruptionWriter(data interface{}, corruptionWriter *Writer, response *Response) {
-	v, ok := data.([]byte)
+func (s *Service) corruptionWriter(data interface{}, rec *record, cause error, corruptionWriter *Writer, response *Response) {
+	v, ok := rec.bytes(data)
 	if ok {
-		s.writeCorrupted(corruptionWriter, v, response)
+		s.writeCorrupted(corruptionWriter, v, rec, cause, response)
 	} else {
 		vj, err := gojay.Marshal(data)
 		if err != nil {
 			response.LogError(fmt.Errorf(" failed to marshal data %+v due to %v", data, err))
 		} else {
-			s.writeCorrupted(corruptionWriter, vj, response)
+			s.writeCorrupted(corruptionWriter, vj, rec, cause, response)
 		}
 	}
 }
@@ -403,36 +573,63 @@ func (s *Service) setTimeoutChannel(ctx context.Context, timeout chan bool) {
 	}
 }
 
-func (s *Service) openWriters(retryURL, corruptionURL string) (*Writer, *Writer) {
+func (s *Service) openWriters(response *Response, envelope *Envelope) (*Writer, *Writer) {
 	var retryWriter, corruptionWriter *Writer
-	if retryURL != "" {
-		retryWriter = NewWriter(retryURL, s.fs)
+	var header func() []byte
+	if envelope == nil { //enveloped records are JSON
+		header = func() []byte {
+			return response.header
+		}
 	}
-	if corruptionURL != "" {
-		corruptionWriter = NewWriter(corruptionURL, s.fs)
+	if response.RetryURL != "" {
+		retryWriter = NewWriter(response.RetryURL, s.fs)
+		retryWriter.envelope = envelope
+		retryWriter.header = header
+		if response.FailedURL != "" { //records exceeding max retries are routed to the failed destination
+			retryWriter.failed = NewWriter(response.FailedURL, s.fs)
+			retryWriter.failed.envelope = envelope
+			retryWriter.maxRetries = s.Config.MaxRetries
+		}
+	}
+	if response.CorruptionURL != "" {
+		corruptionWriter = NewWriter(response.CorruptionURL, s.fs)
+		corruptionWriter.envelope = envelope
+		corruptionWriter.header = header
 	}
 	return retryWriter, corruptionWriter
 }
 
-func (s *Service) makeURL(response *Response, request *Request) {
+func (s *Service) makeURL(ctx context.Context, response *Response, request *Request) {
 	response.Destination = s.Config.ExpandDestination(request.StartTime)
 
 	if s.Config.CorruptionURL != "" {
 		response.CorruptionURL = expandURL(request.TransformSourceURL(s.Config.CorruptionURL), request.StartTime)
 	}
 	retryURL := s.Config.RetryURL
-	if request.Retry() >= s.Config.MaxRetries {
+	delay := s.Config.Backoff.Delay(request.Retry() + 1)
+	if s.Config.Envelope { //retries are accounted per enveloped record
+		if s.Config.FailedURL != "" {
+			response.FailedURL = expandRetryURL(request.TransformSourceURL(s.Config.FailedURL), request.StartTime, request.Retry())
+		}
+	} else if request.Retry() >= s.Config.MaxRetries {
 		retryURL = s.Config.FailedURL
+		delay = 0
 	}
 	if retryURL == "" {
 		return
 	}
 	retryURL = request.TransformSourceURL(retryURL)
 	retryURL = expandRetryURL(retryURL, request.StartTime, request.Retry())
+	if delay > 0 { //retry data is written before deadline
+		retryURL = withNotBefore(retryURL, s.Config.Deadline(ctx).Add(delay))
+	}
 	response.RetryURL = retryURL
-	if request.SourceType == Parquet {
-		response.CorruptionURL = strings.Replace(response.CorruptionURL, ".parquet", ".json.gz", 1)
-		response.RetryURL = strings.Replace(response.RetryURL, ".parquet", ".json.gz", 1)
+	if _, ok := LookupDecoder(request.SourceType).(BinaryDecoder); ok { //binary records are rewritten as JSON
+		for _, ext := range registeredDecoders.Extensions(request.SourceType) {
+			response.CorruptionURL = strings.Replace(response.CorruptionURL, ext, ".json.gz", 1)
+			response.RetryURL = strings.Replace(response.RetryURL, ext, ".json.gz", 1)
+			response.FailedURL = strings.Replace(response.FailedURL, ext, ".json.gz", 1)
+		}
 	}
 }
 
@@ -454,98 +651,123 @@ func (s *Service) StartMetricsEndpoint() {
 func (s *Service) closeWriters(response *Response, retryWriter *Writer, corruptionWriter *Writer) {
 	if retryWriter != nil {
 		response.LogError(retryWriter.Close())
+		response.addWritten(retryWriter)
+		response.addWritten(retryWriter.failed)
 	}
 	if corruptionWriter != nil {
 		response.LogError(corruptionWriter.Close())
+		response.addWritten(corruptionWriter)
 	}
 }
 
-func (s *Service) loadInBatches(ctx context.Context, batchSize int, scanner *bufio.Scanner, deadline time.Time, retryWriter *Writer, response *Response, stream chan interface{}) {
-	batch := make([][]byte, 0)
-	for scanner.Scan() {
-		bs := scanner.Bytes()
-		data := make([]byte, len(bs))
-		copy(data, bs)
-		batch = append(batch, data)
+func (s *Service) loadInBatches(ctx context.Context, batchSize int, source *source, deadline time.Time, retryWriter *Writer, response *Response, stream chan *record) {
+	budget := budgetOf(ctx)
+	batch := &batch{}
+	for {
+		rec := source.nextLine()
+		if rec == nil {
+			break
+		}
+		if !batch.isConsecutive(rec.line) { //keep batch records consecutive for checkpoint
+			budget.send(stream, batch.flush())
+			response.Batched++
+		}
+		batch.append(rec)
 
 		if time.Now().After(deadline) {
-			s.writeToRetry(retryWriter, bytes.Join(batch, []byte("\n")), response)
 			response.LoadTimeouts++
-			batch = make([][]byte, 0)
+			if source.progress != nil { //remaining records are processed from the checkpoint
+				source.progress.truncate(batch.line)
+				return
+			}
+			pending := batch.flush()
+			s.retryWriter(pending.data, pending, errNotProcessed, retryWriter, response)
 			continue
 		}
 		response.Loaded++
-		if len(batch) >= batchSize {
-			stream <- bytes.Join(batch, []byte("\n"))
-			batch = make([][]byte, 0)
+		if batch.size() >= batchSize {
+			budget.send(stream, batch.flush())
 			response.Batched++
 		}
 	}
-	if len(batch) > 0 {
-		stream <- bytes.Join(batch, []byte("\n"))
+	if batch.size() > 0 {
+		budget.send(stream, batch.flush())
 		response.Batched++
 	}
 }
 
-func (s *Service) loadInGroups(ctx context.Context, scanner *bufio.Scanner, deadline time.Time, retryWriter *Writer, response *Response, stream chan interface{}) {
-	batch := make([][]byte, 0)
+func (s *Service) loadInGroups(ctx context.Context, source *source, deadline time.Time, retryWriter *Writer, response *Response, stream chan *record) {
+	budget := budgetOf(ctx)
+	batch := &batch{}
 	groupValue := ""
 	spec := &s.Config.Sort.Spec
-	groupField := s.Config.Sort.By[0]
+	groupField, err := s.Config.Sort.By[0].resolve(source.columns())
+	if err != nil {
+		response.LogError(err)
+		return
+	}
 	flushGroup := false
-	for scanner.Scan() {
-		bs := scanner.Bytes()
-		data := make([]byte, len(bs))
-		copy(data, bs)
+	for {
+		rec := source.nextLine()
+		if rec == nil {
+			break
+		}
 
-		nextValue := toolbox.AsString(groupField.Value(data, spec))
-		if len(batch) == 0 {
+		nextValue := toolbox.AsString(groupField.Value(rec.data.([]byte), spec))
+		if batch.size() == 0 {
 			groupValue = nextValue
-		} else if nextValue != groupValue {
+		} else if nextValue != groupValue || !batch.isConsecutive(rec.line) {
 			flushGroup = true
 		}
 		groupValue = nextValue
 		if time.Now().After(deadline) {
-			batch = append(batch, data)
-			s.writeToRetry(retryWriter, bytes.Join(batch, []byte("\n")), response)
 			response.LoadTimeouts++
-			batch = make([][]byte, 0)
+			if source.progress != nil { //remaining records are processed from the checkpoint
+				line := rec.line
+				if batch.size() > 0 {
+					line = batch.line
+				}
+				source.progress.truncate(line)
+				return
+			}
+			batch.append(rec)
+			pending := batch.flush()
+			s.retryWriter(pending.data, pending, errNotProcessed, retryWriter, response)
 			continue
 		}
 
 		response.Loaded++
 		if flushGroup {
-			stream <- bytes.Join(batch, []byte("\n"))
-			batch = make([][]byte, 0)
+			budget.send(stream, batch.flush())
 			response.Batched++
 			flushGroup = false
 		}
-		batch = append(batch, data)
-		if s.Config.BatchSize > 0 && len(batch) == s.Config.BatchSize {
+		batch.append(rec)
+		if s.Config.BatchSize > 0 && batch.size() == s.Config.BatchSize {
 			flushGroup = true
 		}
 	}
-	if len(batch) > 0 {
-		stream <- bytes.Join(batch, []byte("\n"))
+	if batch.size() > 0 {
+		budget.send(stream, batch.flush())
 		response.Batched++
 	}
 }
 
-func (s *Service) writeToRetry(writer *Writer, data []byte, response *Response) {
+func (s *Service) writeToRetry(writer *Writer, data []byte, rec *record, cause error, response *Response) {
 	if writer == nil {
 		return
 	}
 	response.Skipped++
-	if err := writer.Write(context.Background(), data); err != nil {
+	if err := writer.writeRecord(context.Background(), data, rec, cause); err != nil {
 		response.LogError(newRetryError(fmt.Sprintf(" failed to write retry data %s due to %v", data, err)))
 	}
 }
 
-func (s *Service) writeCorrupted(writer *Writer, data []byte, response *Response) {
+func (s *Service) writeCorrupted(writer *Writer, data []byte, rec *record, cause error, response *Response) {
 	if writer == nil {
 		return
 	}
-	if err := writer.Write(context.Background(), data); err != nil {
+	if err := writer.writeRecord(context.Background(), data, rec, cause); err != nil {
 		response.LogError(newRetryError(fmt.Sprintf(" failed to write corrupted data %s due to %v", data, err)))
 	}
 }
@@ -554,7 +776,7 @@ func (s *Service) onDone(ctx context.Context, request *Request) error {
 	if readerCloser := request.ReadCloser; readerCloser != nil {
 		readerCloser.Close()
 	}
-	if s.Config.OnDone == "" || url.Scheme(request.SourceURL, "") == "" {
+	if s.Config.OnDone == "" || url.Scheme(request.SourceURL, "") == "" || request.Range != nil { //range source is shared by all ranges
 		return nil
 	}
 	switch strings.ToLower(s.Config.OnDone) {
@@ -569,7 +791,7 @@ func (s *Service) onDone(ctx context.Context, request *Request) error {
 }
 
 func (s *Service) onMirror(ctx context.Context, request *Request) error {
-	if s.Config.OnMirrorURL == "" || url.Scheme(request.SourceURL, "") == "" {
+	if s.Config.OnMirrorURL == "" || url.Scheme(request.SourceURL, "") == "" || request.Range != nil {
 		return nil
 	}
 	urlPath := url.Path(request.SourceURL)
@@ -577,10 +799,6 @@ func (s *Service) onMirror(ctx context.Context, request *Request) error {
 	return s.fs.Copy(ctx, request.SourceURL, mirrorURL)
 }
 
-func (s *Service) sortInput(reader io.Reader, response *Response) (io.Reader, error) {
-	return s.Config.Sort.Order(reader, s.Config)
-}
-
 // New creates data processing service
 func New(config *Config, fs afs.Service, processor Processor, reporterProvider func() Reporter) *Service {
 	return &Service{Config: config,
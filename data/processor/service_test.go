@@ -346,6 +346,7 @@ func TestService_Process(t *testing.T) {
 }
 
 type sumKey string
+
 type sumProcessor struct {
 	fs afs.Service
 
@@ -447,3 +448,79 @@ func (p *dataStoreSumProcessor) Post(ctx context.Context, reporter Reporter) err
 	}
 	return nil
 }
+
+func TestService_Do_RecordTimeout(t *testing.T) {
+	fs := afs.New()
+	var useCases = []struct {
+		description     string
+		processor       Processor
+		expectProcessed int32
+		expectRetry     string
+		expectLeaks     int32
+		checkpointURL   string
+	}{
+		{
+			description:     "cooperative cancellation",
+			processor:       &sleepProcessor{sleepOn: "2", sleepTime: time.Second, cooperative: true},
+			expectProcessed: 2,
+			expectRetry:     "2",
+		},
+		{
+			description:     "ignored cancellation",
+			processor:       &sleepProcessor{sleepOn: "2", sleepTime: time.Second},
+			expectProcessed: 2,
+			expectRetry:     "2",
+			expectLeaks:     1,
+		},
+		{
+			description:     "checkpoint",
+			processor:       &sleepProcessor{sleepOn: "2", sleepTime: time.Second, cooperative: true},
+			expectProcessed: 2,
+			expectRetry:     "2",
+			checkpointURL:   "mem://localhost/tmp/timeout/checkpoint/",
+		},
+	}
+	for _, useCase := range useCases {
+		cfg := &Config{Concurrency: 1,
+			MaxExecTimeMs:   5000,
+			RecordTimeoutMs: 100,
+			RetryURL:        "mem://localhost/tmp/timeout/retry/",
+			MaxRetries:      3,
+			CheckpointURL:   useCase.checkpointURL,
+		}
+		srv := New(cfg, fs, useCase.processor, NewReporter)
+		started := time.Now()
+		reporter := srv.Do(context.Background(), NewRequest(strings.NewReader("1\n2\n3"), nil, "mem://localhost/data/timeout/numbers.txt"))
+		response := reporter.BaseResponse()
+		assert.True(t, time.Since(started) < time.Second, useCase.description)
+		assert.EqualValues(t, useCase.expectProcessed, response.Processed, useCase.description)
+		assert.EqualValues(t, useCase.expectLeaks, response.ProcessLeaks, useCase.description)
+		assert.Empty(t, response.CheckpointURL, useCase.description) //timed out record is retried, not resumed
+		retry, err := fs.DownloadWithURL(context.Background(), response.RetryURL)
+		if assert.Nil(t, err, useCase.description) {
+			assert.Equal(t, useCase.expectRetry, string(retry), useCase.description)
+		}
+	}
+}
+
+type sleepProcessor struct {
+	sleepOn     string
+	sleepTime   time.Duration
+	cooperative bool
+}
+
+func (p *sleepProcessor) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	if string(data.([]byte)) != p.sleepOn {
+		return nil
+	}
+	if !p.cooperative {
+		time.Sleep(p.sleepTime)
+		return nil
+	}
+	select {
+	case <-time.After(p.sleepTime):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -3,6 +3,7 @@ package processor
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"github.com/francoispqt/gojay"
 	"github.com/viant/cloudless/ioutil"
 	"io"
@@ -20,8 +21,10 @@ type (
 	//Sort represents configuration sort definition
 	Sort struct {
 		Spec
-		By    []Field
-		Batch bool //batches data by first sorted field
+		By             []Field
+		Batch          bool   //batches data by first sorted field
+		MemoryBudgetMB int    //if set, input exceeding memory budget is sorted externally (spill-to-disk merge sort)
+		TempURL        string //external sort runs location, os temp dir by default
 	}
 	//Sort represents sort field definition
 	Field struct {
@@ -89,8 +92,41 @@ func (f *Field) Value(data []byte, spec *Spec) interface{} {
 	return fields.values[f.Name]
 }
 
+// resolve returns field with CSV column index resolved by the field name, it returns an error if the name does not match any column
+func (f Field) resolve(columns []string) (Field, error) {
+	if f.Name == "" || len(columns) == 0 {
+		return f, nil
+	}
+	for i, column := range columns {
+		if strings.EqualFold(strings.TrimSpace(column), f.Name) {
+			f.Index = i
+			return f, nil
+		}
+	}
+	return f, fmt.Errorf("unknown sort column: %v, available: %v", f.Name, columns)
+}
+
+// withColumns returns sort with CSV field indexes resolved by column names
+func (s Sort) withColumns(columns []string) (Sort, error) {
+	if len(columns) == 0 {
+		return s, nil
+	}
+	by := make([]Field, len(s.By))
+	for i, field := range s.By {
+		var err error
+		if by[i], err = field.resolve(columns); err != nil {
+			return s, err
+		}
+	}
+	s.By = by
+	return s, nil
+}
+
 // Order orders the reader data
 func (s Sort) Order(reader io.Reader, config *Config) (io.Reader, error) {
+	if s.MemoryBudgetMB > 0 {
+		return s.orderExternally(reader, config, s.MemoryBudgetMB*1024*1024)
+	}
 	scanner := bufio.NewScanner(reader)
 	if config != nil {
 		config.AdjustScannerBuffer(scanner)
@@ -116,15 +152,17 @@ func (s Sort) Order(reader io.Reader, config *Config) (io.Reader, error) {
 		err = nil
 	}
 	sort.Sort(sorables)
-	////remove new line from the last item
-	lastIndex := len(sorables.Items) - 1
+	return sorables.reader(), err
+}
+
+func (s *Sortables) reader() io.Reader {
+	//remove new line from the last item
+	lastIndex := len(s.Items) - 1
 	if lastIndex >= 0 {
-		last := sorables.Items[lastIndex]
-		sorables.Items[lastIndex] = bytes.Trim(last, "\n")
+		last := s.Items[lastIndex]
+		s.Items[lastIndex] = bytes.Trim(last, "\n")
 	}
-	return ioutil.BytesSliceReader(sorables.Items), nil
-	//data := bytes.Join(sorables.Errors, []byte("\n"))
-	//return bytes.NewReader(data), err
+	return ioutil.BytesSliceReader(s.Items)
 }
 
 // Len is part of sort.Interface.
@@ -139,20 +177,24 @@ func (s *Sortables) Swap(i, j int) {
 
 // Less is part of sort.Interface
 func (s *Sortables) Less(srcIdx, destIdx int) bool {
+	return s.Sort.less(s.Items[srcIdx], s.Items[destIdx])
+}
+
+func (s *Sort) less(srcItem, destItem []byte) bool {
 	switch strings.ToLower(s.Format) {
 	case "csv":
-		return s.csvLess(srcIdx, destIdx)
+		return s.csvLess(srcItem, destItem)
 	}
-	return s.jsonLess(srcIdx, destIdx)
+	return s.jsonLess(srcItem, destItem)
 }
 
-func (s *Sortables) csvLess(srcIdx int, destIdx int) bool {
+func (s *Sort) csvLess(srcItem, destItem []byte) bool {
 	delimiter := s.Delimiter
 	if delimiter == "" {
 		delimiter = ","
 	}
-	src := bytes.Split(s.Items[srcIdx], []byte(delimiter))
-	dest := bytes.Split(s.Items[destIdx], []byte(delimiter))
+	src := bytes.Split(srcItem, []byte(delimiter))
+	dest := bytes.Split(destItem, []byte(delimiter))
 	for _, field := range s.By {
 		if field.IsNumeric {
 			srcValue := bytesToInt(src, field.Index)
@@ -171,11 +213,11 @@ func (s *Sortables) csvLess(srcIdx int, destIdx int) bool {
 	return false
 }
 
-func (s *Sortables) jsonLess(srcIdx int, destIdx int) bool {
-	src := Fields{values: map[string]interface{}{}, Sort: s.Sort}
-	dest := Fields{values: map[string]interface{}{}, Sort: s.Sort}
-	gojay.Unmarshal(s.Items[srcIdx], &src)
-	gojay.Unmarshal(s.Items[destIdx], &dest)
+func (s *Sort) jsonLess(srcItem, destItem []byte) bool {
+	src := Fields{values: map[string]interface{}{}, Sort: *s}
+	dest := Fields{values: map[string]interface{}{}, Sort: *s}
+	gojay.Unmarshal(srcItem, &src)
+	gojay.Unmarshal(destItem, &dest)
 	if len(src.values) == 0 {
 		return true
 	}
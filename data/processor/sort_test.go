@@ -1,8 +1,13 @@
 package processor
 
 import (
+	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"io"
 	iou "io/ioutil"
+	"os"
 	"strings"
 	"testing"
 )
@@ -93,3 +98,117 @@ zz,3,abc`,
 		assert.EqualValues(t, useCase.expect, string(output), useCase.description)
 	}
 }
+
+func TestSort_OrderExternally(t *testing.T) {
+	var csvInput, jsonInput []string
+	for i := 0; i < 50; i++ {
+		id := (i * 37) % 50
+		csvInput = append(csvInput, fmt.Sprintf("name%v,%v,x", id, id))
+		jsonInput = append(jsonInput, fmt.Sprintf(`{"batch":"%v", "id":%v}`, id%3, id))
+	}
+	var useCases = []struct {
+		description string
+		sort        Sort
+		input       string
+	}{
+		{
+			description: "CSV numeric order by",
+			input:       strings.Join(csvInput, "\n"),
+			sort: Sort{
+				Spec: Spec{Format: "CSV", Delimiter: ","},
+				By:   []Field{{Index: 1, IsNumeric: true}},
+			},
+		},
+		{
+			description: "JSON multi field order by",
+			input:       strings.Join(jsonInput, "\n"),
+			sort: Sort{
+				Spec: Spec{Format: "JSON"},
+				By:   []Field{{Name: "batch"}, {Name: "id", IsNumeric: true}},
+			},
+		},
+	}
+
+	fs := afs.New()
+	for _, useCase := range useCases {
+		expect, _ := useCase.sort.Order(strings.NewReader(useCase.input), nil)
+		expectOutput, _ := iou.ReadAll(expect)
+		useCase.sort.TempURL = "mem://localhost/tmp/sort"
+		actual, err := useCase.sort.orderExternally(strings.NewReader(useCase.input), nil, 128)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		_, isMerged := actual.(*mergeReader)
+		assert.True(t, isMerged, useCase.description)
+		output, err := iou.ReadAll(actual)
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, string(expectOutput), string(output), useCase.description)
+		objects, _ := fs.List(context.Background(), useCase.sort.TempURL)
+		assert.True(t, len(objects) <= 1, useCase.description)
+	}
+}
+
+func TestSort_OrderExternally_Cleanup(t *testing.T) {
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("name%v,%v,x", i, (i*37)%50))
+	}
+	sortBy := Sort{Spec: Spec{Format: "CSV", Delimiter: ","}, By: []Field{{Index: 1, IsNumeric: true}}}
+	var useCases = []struct {
+		description string
+		input       string
+		hasError    bool
+	}{
+		{description: "closed before EOF", input: strings.Join(lines, "\n")},
+		{description: "scanner error", input: strings.Join(lines, "\n") + "\n" + strings.Repeat("x", 2*1024*1024), hasError: true},
+	}
+	for _, useCase := range useCases {
+		tempDir := t.TempDir()
+		sortBy.TempURL = tempDir
+		actual, err := sortBy.orderExternally(strings.NewReader(useCase.input), &Config{ScannerBufferMB: 1}, 128)
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+		} else if assert.Nil(t, err, useCase.description) {
+			_, err = actual.Read(make([]byte, 10))
+			assert.Nil(t, err, useCase.description)
+			entries, _ := os.ReadDir(tempDir)
+			assert.Equal(t, 1, len(entries), useCase.description) //sorted runs location
+			closer, ok := actual.(io.Closer)
+			if assert.True(t, ok, useCase.description) {
+				assert.Nil(t, closer.Close(), useCase.description)
+			}
+		}
+		entries, err := os.ReadDir(tempDir)
+		assert.Nil(t, err, useCase.description)
+		assert.Equal(t, 0, len(entries), useCase.description)
+	}
+}
+
+func TestSort_WithColumns(t *testing.T) {
+	var useCases = []struct {
+		description string
+		columns     []string
+		by          []Field
+		expect      []int
+		hasError    bool
+	}{
+		{description: "resolved by name", columns: []string{"id", " Name "}, by: []Field{{Name: "name"}, {Index: 0}}, expect: []int{1, 0}},
+		{description: "unknown columns", by: []Field{{Name: "name"}}, expect: []int{0}},
+		{description: "unknown column name", columns: []string{"id", "team"}, by: []Field{{Name: "name"}}, hasError: true},
+	}
+	for _, useCase := range useCases {
+		actual, err := Sort{By: useCase.by}.withColumns(useCase.columns)
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		var indexes []int
+		for _, field := range actual.By {
+			indexes = append(indexes, field.Index)
+		}
+		assert.Equal(t, useCase.expect, indexes, useCase.description)
+	}
+}
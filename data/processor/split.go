@@ -0,0 +1,205 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/viant/afs"
+	"github.com/viant/afs/option"
+	"github.com/viant/cloudless/async/mbus"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRangeSizeMB = 64
+	rangePartSize      = 8 * 1024 * 1024
+	rangeScanSize      = 64 * 1024
+)
+
+type (
+	// ByteRange represents new line aligned source byte range
+	ByteRange struct {
+		SourceURL string
+		Offset    int64
+		Length    int64
+		Index     int //range number
+		Count     int //number of source ranges
+	}
+
+	// Splitter represents large source splitter publishing one work message per new line aligned byte range
+	Splitter struct {
+		RangeSizeMB int            //max range size, 64MB by default
+		Dest        *mbus.Resource //work message destination
+		Service     mbus.Service   //optional message service, looked up by Dest.Vendor by default
+		SourceType  string         //optional source type, detected by source URL extension by default
+		fs          afs.Service
+	}
+
+	rangeReader struct {
+		io.Reader
+		closer io.Closer
+	}
+)
+
+// Close closes underlying source reader
+func (r *rangeReader) Close() error {
+	return r.closer.Close()
+}
+
+// Ranges computes new line aligned byte ranges of the source
+func (s *Splitter) Ranges(ctx context.Context, URL string) ([]*ByteRange, error) {
+	if strings.HasSuffix(URL, ".gz") {
+		return nil, fmt.Errorf("compressed source can not be split: %v", URL)
+	}
+	sourceType := s.SourceType
+	if sourceType == "" {
+		sourceType = DetectSourceType(URL)
+	}
+	switch decoder := LookupDecoder(sourceType).(type) {
+	case BinaryDecoder:
+		return nil, fmt.Errorf("binary source can not be split: %v", URL)
+	case *textDecoder:
+		if decoder.header { //only the first range would have the header
+			return nil, fmt.Errorf("source with header can not be split: %v", URL)
+		}
+	}
+	fs := s.fileService()
+	object, err := fs.Object(ctx, URL)
+	if err != nil {
+		return nil, err
+	}
+	size := object.Size()
+	rangeSize := int64(s.RangeSizeMB) * 1024 * 1024
+	if rangeSize <= 0 {
+		rangeSize = defaultRangeSizeMB * 1024 * 1024
+	}
+	var result []*ByteRange
+	for offset := int64(0); offset < size; {
+		end := offset + rangeSize
+		if end < size {
+			if end, err = lineEnd(ctx, fs, URL, size, end-1); err != nil {
+				return nil, err
+			}
+		}
+		if end > size {
+			end = size
+		}
+		result = append(result, &ByteRange{SourceURL: URL, Offset: offset, Length: end - offset, Index: len(result)})
+		offset = end
+	}
+	for _, aRange := range result {
+		aRange.Count = len(result)
+	}
+	return result, nil
+}
+
+// lineEnd returns offset following the first new line at or after the offset, or the source size
+func lineEnd(ctx context.Context, fs afs.Service, URL string, size, offset int64) (int64, error) {
+	reader, err := openRange(ctx, fs, URL, size, offset, size-offset)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close() }()
+	buffer := make([]byte, rangeScanSize)
+	for offset < size {
+		n, err := reader.Read(buffer)
+		if index := bytes.IndexByte(buffer[:n], '\n'); index != -1 {
+			return offset + int64(index) + 1, nil
+		}
+		offset += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// Split publishes a work message with ByteRange payload for every source range
+func (s *Splitter) Split(ctx context.Context, URL string) ([]*ByteRange, error) {
+	ranges, err := s.Ranges(ctx, URL)
+	if err != nil {
+		return nil, err
+	}
+	if s.Dest == nil {
+		return nil, fmt.Errorf("split destination was empty")
+	}
+	service := s.Service
+	if service == nil {
+		if service = mbus.Lookup(s.Dest.Vendor); service == nil {
+			return nil, fmt.Errorf("unsupported message vendor: %v", s.Dest.Vendor)
+		}
+	}
+	for _, aRange := range ranges {
+		message := &mbus.Message{Subject: URL, Data: aRange}
+		if _, err = service.Push(ctx, s.Dest, message); err != nil {
+			return nil, fmt.Errorf("failed to publish range %v of %v, due to %w", aRange.Index, URL, err)
+		}
+	}
+	return ranges, nil
+}
+
+// fileService returns splitter file service, splitter created without NewSplitter uses the default service
+func (s *Splitter) fileService() afs.Service {
+	if s.fs != nil {
+		return s.fs
+	}
+	return afs.New()
+}
+
+// NewSplitter creates a splitter
+func NewSplitter(fs afs.Service, dest *mbus.Resource, rangeSizeMB int) *Splitter {
+	return &Splitter{fs: fs, Dest: dest, RangeSizeMB: rangeSizeMB}
+}
+
+// openRange opens source byte range, sources streamed with range reads (i.e. s3, gs) are read only within the range
+func openRange(ctx context.Context, fs afs.Service, URL string, size, offset, length int64) (io.ReadCloser, error) {
+	reader, err := fs.OpenURL(ctx, URL, option.NewStream(rangePartSize, int(size)))
+	if err != nil {
+		return nil, err
+	}
+	if readerAt, ok := reader.(io.ReaderAt); ok {
+		return &rangeReader{Reader: io.NewSectionReader(readerAt, offset, length), closer: reader}, nil
+	}
+	if _, err = io.CopyN(io.Discard, reader, offset); err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("failed to skip to range offset %v of %v, due to %w", offset, URL, err)
+	}
+	return &rangeReader{Reader: io.LimitReader(reader, length), closer: reader}, nil
+}
+
+// NewRangeRequest creates a processing request reading only the source byte range
+func NewRangeRequest(ctx context.Context, fs afs.Service, byteRange *ByteRange) (*Request, error) {
+	object, err := fs.Object(ctx, byteRange.SourceURL)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange.Offset+byteRange.Length > object.Size() {
+		return nil, fmt.Errorf("invalid range %v-%v, %v size: %v", byteRange.Offset, byteRange.Offset+byteRange.Length, byteRange.SourceURL, object.Size())
+	}
+	reader, err := openRange(ctx, fs, byteRange.SourceURL, object.Size(), byteRange.Offset, byteRange.Length)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{
+		ReadCloser: reader,
+		SourceType: DetectSourceType(byteRange.SourceURL),
+		Range:      byteRange,
+		StartTime:  time.Now(),
+		SourceURL:  byteRange.SourceURL,
+	}, nil
+}
+
+// withRange adds range fragment to the URL file name, i.e. data-range0003.csv
+func withRange(URL string, index int) string {
+	fragment := RangeFragment + fmt.Sprintf("%04d", index)
+	nameIndex := strings.LastIndex(URL, "/") + 1
+	if extIndex := strings.Index(URL[nameIndex:], "."); extIndex != -1 {
+		return URL[:nameIndex+extIndex] + fragment + URL[nameIndex+extIndex:]
+	}
+	return URL + fragment
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/cloudless/async/mbus"
+	"github.com/viant/cloudless/async/mbus/mem"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSplitter_Split(t *testing.T) {
+	ctx := context.Background()
+	fs := afs.New()
+	var lines []string
+	var expectSum int64
+	padding := strings.Repeat("x", 48)
+	for i := 0; i < 50000; i++ { //~2.7MB
+		lines = append(lines, strconv.Itoa(i)+","+padding)
+		expectSum += int64(i)
+	}
+	sourceURL := path.Join(t.TempDir(), "data.csv") //mem objects do not report size
+	assert.Nil(t, fs.Upload(ctx, sourceURL, 0644, strings.NewReader(strings.Join(lines, "\n"))))
+	dest := &mbus.Resource{Name: "splitTest", Vendor: "mem", Type: mbus.ResourceTypeQueue}
+	splitter := NewSplitter(fs, dest, 1)
+	ranges, err := splitter.Split(ctx, sourceURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 3, len(ranges))
+
+	var sum, count int64
+	processor := NewTyped(func(ctx context.Context, event *typedEvent, reporter Reporter) error {
+		atomic.AddInt64(&sum, int64(event.ID))
+		atomic.AddInt64(&count, 1)
+		return nil
+	}, mustCSVDecoder[typedEvent](",", "id", "name"))
+	srv := New(&Config{Concurrency: 4, MaxExecTimeMs: 60000, BatchSize: 500, MaxRetries: 3, OnDone: OnDoneDelete}, fs, processor, NewReporter)
+	queue := mem.Singleton().Queue(dest)
+	for i := range ranges {
+		message := <-queue
+		payload, err := message.Payload()
+		assert.Nil(t, err)
+		byteRange := &ByteRange{}
+		assert.Nil(t, json.Unmarshal(payload, byteRange))
+		assert.Equal(t, ranges[i], byteRange)
+		assert.Equal(t, len(ranges), byteRange.Count)
+
+		request, err := NewRangeRequest(ctx, fs, byteRange)
+		if !assert.Nil(t, err) {
+			return
+		}
+		response := srv.Do(ctx, request).BaseResponse()
+		assert.Equal(t, StatusOk, response.Status, response.Errors)
+	}
+	assert.EqualValues(t, len(lines), count)
+	assert.EqualValues(t, expectSum, sum)
+	exists, _ := fs.Exists(ctx, sourceURL)
+	assert.True(t, exists, "range source should not be deleted on done")
+}
+
+func TestSplitter_Ranges(t *testing.T) {
+	ctx := context.Background()
+	sourceURL := path.Join(t.TempDir(), "data.csv")
+	assert.Nil(t, afs.New().Upload(ctx, sourceURL, 0644, strings.NewReader("id,name\n1,a\n2,b")))
+	var useCases = []struct {
+		description string
+		splitter    *Splitter
+		URL         string
+		expect      int
+		hasError    bool
+	}{
+		{description: "default file service", splitter: &Splitter{}, URL: sourceURL, expect: 1},
+		{description: "source with header", splitter: &Splitter{SourceType: CSVWithHeader}, URL: sourceURL, hasError: true},
+		{description: "compressed source", splitter: &Splitter{}, URL: sourceURL + ".gz", hasError: true},
+		{description: "binary source", splitter: &Splitter{}, URL: path.Join(t.TempDir(), "data.avro"), hasError: true},
+	}
+	for _, useCase := range useCases {
+		ranges, err := useCase.splitter.Ranges(ctx, useCase.URL)
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		assert.Nil(t, err, useCase.description)
+		assert.Equal(t, useCase.expect, len(ranges), useCase.description)
+	}
+}
+
+func TestWithRange(t *testing.T) {
+	var useCases = []struct {
+		description string
+		URL         string
+		index       int
+		expect      string
+	}{
+		{description: "extension", URL: "s3://bucket.x/path/data.csv.gz", index: 3, expect: "s3://bucket.x/path/data-range0003.csv.gz"},
+		{description: "no extension", URL: "/path/data", index: 12, expect: "/path/data-range0012"},
+	}
+	for _, useCase := range useCases {
+		assert.Equal(t, useCase.expect, withRange(useCase.URL, useCase.index), useCase.description)
+	}
+}
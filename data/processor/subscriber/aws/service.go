@@ -18,6 +18,8 @@ import (
 	"time"
 )
 
+const maxVisibilityTimeout = 43200 // 12 hours allowed max
+
 //Service represents sqs service
 type Service struct {
 	config    *Config
@@ -97,6 +99,19 @@ func (s *Service) deleteMessage(msg *sqs.Message) error {
 	return err
 }
 
+func (s *Service) releaseMessage(msg *sqs.Message, delay time.Duration) error {
+	visibilityTimeout := int64(delay.Seconds())
+	if visibilityTimeout > maxVisibilityTimeout {
+		visibilityTimeout = maxVisibilityTimeout
+	}
+	_, err := s.sqsClient.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          s.queueURL,
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: &visibilityTimeout,
+	})
+	return err
+}
+
 func (s *Service) Messages() chan *sqs.Message {
 	return s.messages
 }
@@ -159,7 +174,13 @@ func (s *Service) handleMessage(ctx context.Context, msg *sqs.Message, fs afs.Se
 		return
 	}
 	reporter := s.processor.Do(reqContext, request)
-	err = s.deleteMessage(msg)
+	if response := reporter.BaseResponse(); response.NotBefore != nil {
+		err = s.releaseMessage(msg, time.Until(*response.NotBefore)) //retry is redelivered once due
+	} else if response.CheckpointURL != "" {
+		err = s.releaseMessage(msg, 0) //processing resumes from the checkpoint with the next delivery
+	} else {
+		err = s.deleteMessage(msg)
+	}
 	if err != nil {
 		stats.Append(err)
 		stats.Append(stat.NegativeAcknowledged)
@@ -67,6 +67,15 @@ func (s *Service) consume(ctx context.Context) error {
 	})
 }
 
+// deferMessage nacks not due message once the delay elapses, the delay is capped by VisibilityTimeout (max ack extension),
+// held message counts against BatchSize outstanding messages, redelivery after the capped delay is paced by the subscription retry policy
+func (s *Service) deferMessage(msg *pubsub.Message, delay time.Duration) {
+	if maxDelay := time.Duration(s.config.VisibilityTimeout) * time.Second; delay > maxDelay {
+		delay = maxDelay
+	}
+	time.AfterFunc(delay, msg.Nack)
+}
+
 func (s *Service) handleMessage(ctx context.Context, msg *pubsub.Message, fs afs.Service) {
 	defer func() {
 		r := recover()
@@ -103,8 +112,16 @@ func (s *Service) handleMessage(ctx context.Context, msg *pubsub.Message, fs afs
 		return
 	}
 	reporter := s.processor.Do(reqContext, request)
-	msg.Ack()
-	stats.Append(stat.Acknowledged)
+	if response := reporter.BaseResponse(); response.NotBefore != nil {
+		s.deferMessage(msg, time.Until(*response.NotBefore))
+		stats.Append(stat.NegativeAcknowledged)
+	} else if response.CheckpointURL != "" { //processing resumes from the checkpoint with the next delivery
+		msg.Nack()
+		stats.Append(stat.NegativeAcknowledged)
+	} else {
+		msg.Ack()
+		stats.Append(stat.Acknowledged)
+	}
 	output, err := json.Marshal(reporter)
 	if err != nil {
 		stats.Append(err)
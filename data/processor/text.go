@@ -0,0 +1,202 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/francoispqt/gojay"
+	"github.com/viant/cloudless/data/processor/registry"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// textDecoder represents new line delimited text decoder (CSV, TSV, NDJSON)
+type textDecoder struct {
+	delimiter string
+	header    bool
+	json      bool
+}
+
+// NewReader creates a text record reader, sorting input if config.Sort is specified
+func (d *textDecoder) NewReader(ctx context.Context, request *Request, config *Config) (RecordReader, error) {
+	if request.ReadCloser == nil {
+		return nil, fmt.Errorf("reader was empty: %v", request.SourceURL)
+	}
+	var reader io.Reader = request.ReadCloser
+	result := &textReader{delimiter: d.delimiter, config: config, attempts: -1}
+	if seeker, ok := request.ReadCloser.(io.ReadSeeker); ok {
+		result.seeker = seeker
+	}
+	if d.header {
+		bufReader := bufio.NewReader(reader)
+		line, err := bufReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read header: %v, due to %w", request.SourceURL, err)
+		}
+		result.offset = int64(len(line))
+		if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+			result.headerRow = line
+			result.header = strings.Split(string(line), d.delimiter)
+		}
+		reader = bufReader
+	}
+	if len(result.header) == 0 && !d.json && config != nil && len(config.Columns) > 0 {
+		result.header = config.Columns
+	}
+	if config != nil && config.Envelope {
+		result.envelope = true
+		if len(config.Sort.By) > 0 { //records are unwrapped before sorting, record attempts are unknown
+			reader = newEnvelopeReader(reader, config)
+		}
+	}
+	if config != nil && len(config.Sort.By) > 0 {
+		sortSpec, err := config.Sort.withColumns(result.header)
+		if err != nil {
+			return nil, err
+		}
+		if reader, err = sortSpec.Order(reader, config); err != nil {
+			return nil, err
+		}
+		if closer, ok := reader.(io.Closer); ok {
+			result.closer = closer
+		}
+		result.offset = -1 //sorted data has no source offsets
+		result.seeker = nil
+	}
+	if d.json && request.RowType != nil {
+		result.rowType = request.RowType
+	}
+	if !d.json && len(result.header) > 0 {
+		rowType := request.RowType
+		if rowType == nil && config != nil && config.RowTypeName != "" {
+			rowType = registry.RowType(config.RowTypeName)
+		}
+		if rowType != nil {
+			var err error
+			if result.csv, err = newCSVMapper(rowType, result.header, d.delimiter); err != nil {
+				_ = result.Close()
+				return nil, err
+			}
+		}
+	}
+	result.reset(reader)
+	return result, nil
+}
+
+type textReader struct {
+	scanner   *bufio.Scanner
+	delimiter string
+	header    []string
+	rowType   reflect.Type
+	offset    int64
+	seeker    io.ReadSeeker
+	config    *Config
+	envelope  bool
+	attempts  int
+	headerRow []byte
+	csv       *csvMapper
+	raw       []byte
+	closer    io.Closer //sorted data reader holding external sort runs
+}
+
+func (r *textReader) reset(reader io.Reader) {
+	r.scanner = bufio.NewScanner(reader)
+	r.scanner.Split(r.scanLines)
+	if r.config != nil {
+		r.config.AdjustScannerBuffer(r.scanner)
+	}
+}
+
+// scanLines splits lines tracking source byte offset
+func (r *textReader) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if r.offset >= 0 {
+		r.offset += int64(advance)
+	}
+	return advance, token, err
+}
+
+// Read reads a text line, JSON line is decoded into a row type if specified
+func (r *textReader) Read() (interface{}, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	bs := r.scanner.Bytes()
+	data := make([]byte, len(bs))
+	copy(data, bs)
+	if r.envelope {
+		data, r.attempts = unwrapEnvelope(data)
+	}
+	if r.csv != nil {
+		r.raw = data
+		return r.csv.decode(data)
+	}
+	if r.rowType == nil {
+		return data, nil
+	}
+	r.raw = data
+	rowPtr := reflect.New(r.rowType).Interface()
+	if err := gojay.Unmarshal(data, rowPtr); err != nil {
+		return nil, NewDataCorruption(fmt.Sprintf("failed to decode %T: %s, due to %v", rowPtr, data, err))
+	}
+	return rowPtr, nil
+}
+
+// Delimiter returns field delimiter, empty for JSON and decoded CSV rows
+func (r *textReader) Delimiter() string {
+	if r.csv != nil {
+		return ""
+	}
+	return r.delimiter
+}
+
+// Offset returns source byte offset of the next line, -1 if unknown
+func (r *textReader) Offset() int64 {
+	return r.offset
+}
+
+// SeekOffset positions reader at the source byte offset
+func (r *textReader) SeekOffset(offset int64) (bool, error) {
+	if r.seeker == nil || r.offset < 0 {
+		return false, nil
+	}
+	if _, err := r.seeker.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+	r.offset = offset
+	r.reset(r.seeker)
+	return true, nil
+}
+
+// Attempts returns processing attempts of the last read enveloped record, -1 if unknown
+func (r *textReader) Attempts() int {
+	return r.attempts
+}
+
+// Header returns header columns, either read from the source or configured
+func (r *textReader) Header() []string {
+	return r.header
+}
+
+// HeaderLine returns source header line, empty if source has no header
+func (r *textReader) HeaderLine() []byte {
+	return r.headerRow
+}
+
+// Raw returns source line of the last decoded record, nil if record was not decoded
+func (r *textReader) Raw() []byte {
+	return r.raw
+}
+
+// Close closes reader, external sort runs are removed
+func (r *textReader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/francoispqt/gojay"
+	"reflect"
+)
+
+type (
+	// RecordDecoder represents a record decoder into the target type
+	RecordDecoder[T any] func(data []byte, target *T) error
+
+	// Typed represents a processor adapter calling a strongly typed handler with every decoded record
+	Typed[T any] struct {
+		Decoder RecordDecoder[T] //optional, JSON decoder by default
+		Handler func(ctx context.Context, record *T, reporter Reporter) error
+	}
+
+	// TypedBatch represents a processor adapter calling a strongly typed handler with all decoded records passed to Process
+	TypedBatch[T any] struct {
+		Decoder RecordDecoder[T] //optional, JSON decoder by default
+		Handler func(ctx context.Context, records []*T, reporter Reporter) error
+	}
+)
+
+// Process decodes data and calls the handler with every record, if the handler fails after some records
+// have been processed, the remaining records are returned with partial retry error,
+// a record failing with data corruption or decoding is reported as corrupted and only the records following it are retried
+func (p *Typed[T]) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	if record, ok := typedRecord[T](data); ok {
+		return p.Handler(ctx, record, reporter)
+	}
+	decoded, err := decodeTyped(data, p.Decoder)
+	if err != nil {
+		return err
+	}
+	for i, record := range decoded.records {
+		if record == nil { //undecodable line is reported as corrupted
+			continue
+		}
+		if err = p.Handler(ctx, record, reporter); err == nil {
+			continue
+		}
+		if !isDataCorruptionError(err) {
+			if i == decoded.first() && decoded.err == nil { //nothing processed, the whole data is retried
+				return err
+			}
+			return &PartialRetry{message: err.Error(), data: decoded.join(i), corrupt: decoded.corrupt(-1)}
+		}
+		if len(decoded.lines) == 1 {
+			return err
+		}
+		return &PartialRetry{message: err.Error(), data: decoded.join(i + 1), corrupt: decoded.corrupt(i)}
+	}
+	if decoded.err != nil { //decoded records have been processed
+		return &PartialRetry{message: decoded.err.Error(), corrupt: decoded.corrupt(-1)}
+	}
+	return nil
+}
+
+// RowType returns record type, used to decode binary sources i.e. parquet
+func (p *Typed[T]) RowType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Process decodes data and calls the handler with all decoded records
+func (p *TypedBatch[T]) Process(ctx context.Context, data interface{}, reporter Reporter) error {
+	if record, ok := typedRecord[T](data); ok {
+		return p.Handler(ctx, []*T{record}, reporter)
+	}
+	decoded, err := decodeTyped(data, p.Decoder)
+	if err != nil {
+		return err
+	}
+	var records []*T
+	for _, record := range decoded.records {
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	if len(records) > 0 {
+		if err = p.Handler(ctx, records, reporter); err != nil {
+			if isDataCorruptionError(err) || decoded.err == nil {
+				return err
+			}
+			return &PartialRetry{message: err.Error(), data: decoded.join(0), corrupt: decoded.corrupt(-1)} //undecodable lines are not retried
+		}
+	}
+	if decoded.err != nil {
+		return &PartialRetry{message: decoded.err.Error(), corrupt: decoded.corrupt(-1)}
+	}
+	return nil
+}
+
+// RowType returns record type, used to decode binary sources i.e. parquet
+func (p *TypedBatch[T]) RowType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// NewTyped creates a typed processor, the first supplied decoder is used if any
+func NewTyped[T any](handler func(ctx context.Context, record *T, reporter Reporter) error, decoder ...RecordDecoder[T]) *Typed[T] {
+	result := &Typed[T]{Handler: handler}
+	if len(decoder) > 0 {
+		result.Decoder = decoder[0]
+	}
+	return result
+}
+
+// NewTypedBatch creates a typed batch processor, the first supplied decoder is used if any
+func NewTypedBatch[T any](handler func(ctx context.Context, records []*T, reporter Reporter) error, decoder ...RecordDecoder[T]) *TypedBatch[T] {
+	result := &TypedBatch[T]{Handler: handler}
+	if len(decoder) > 0 {
+		result.Decoder = decoder[0]
+	}
+	return result
+}
+
+// JSONDecoder returns JSON record decoder, gojay is used if the target implements gojay.UnmarshalerJSONObject
+func JSONDecoder[T any]() RecordDecoder[T] {
+	return func(data []byte, target *T) error {
+		if unmarshaler, ok := any(target).(gojay.UnmarshalerJSONObject); ok {
+			return gojay.UnmarshalJSONObject(data, unmarshaler)
+		}
+		return json.Unmarshal(data, target)
+	}
+}
+
+// CSVDecoder returns CSV record decoder, struct fields are matched with columns by csv tag or field name
+func CSVDecoder[T any](delimiter string, columns ...string) (RecordDecoder[T], error) {
+	mapper, err := newCSVMapper(reflect.TypeOf((*T)(nil)).Elem(), columns, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	return func(data []byte, target *T) error {
+		record, err := mapper.decode(data)
+		if err != nil {
+			return err
+		}
+		*target = *(record.(*T))
+		return nil
+	}, nil
+}
+
+// typedRecord returns already decoded record, i.e. parquet or CSV with header row
+func typedRecord[T any](data interface{}) (*T, bool) {
+	switch actual := data.(type) {
+	case *T:
+		return actual, true
+	case T:
+		return &actual, true
+	}
+	return nil, false
+}
+
+// typedLines represents decoded new line delimited records
+type typedLines[T any] struct {
+	lines   [][]byte //non empty lines
+	records []*T     //decoded line records, nil for undecodable line
+	err     error    //the first line decoding failure
+}
+
+// first returns index of the first decoded record
+func (d *typedLines[T]) first() int {
+	for i, record := range d.records {
+		if record != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// join returns decoded lines starting from the index, nil if there are none
+func (d *typedLines[T]) join(index int) []byte {
+	var lines [][]byte
+	for i := index; i < len(d.lines); i++ {
+		if d.records[i] != nil {
+			lines = append(lines, d.lines[i])
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// corrupt returns undecodable lines along with the failed line (if not -1) in the source order, nil if there are none
+func (d *typedLines[T]) corrupt(failed int) []byte {
+	var lines [][]byte
+	for i, line := range d.lines {
+		if d.records[i] == nil || i == failed {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// decodeTyped decodes new line delimited records, data is reported as corrupted only if none of its records can be decoded
+func decodeTyped[T any](data interface{}, decoder RecordDecoder[T]) (*typedLines[T], error) {
+	bs, ok := data.([]byte)
+	if !ok {
+		return nil, NewDataCorruption(fmt.Sprintf("unsupported record type: %T, expected %T", data, (*T)(nil)))
+	}
+	if decoder == nil {
+		decoder = JSONDecoder[T]()
+	}
+	result := &typedLines[T]{}
+	for _, line := range bytes.Split(bs, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		record := new(T)
+		if err := decoder(line, record); err != nil {
+			if !isDataCorruptionError(err) {
+				err = NewDataCorruption(fmt.Sprintf("failed to decode %T: %s, due to %v", record, line, err))
+			}
+			if result.err == nil {
+				result.err = err
+			}
+			record = nil
+		}
+		result.lines = append(result.lines, line)
+		result.records = append(result.records, record)
+	}
+	if result.err != nil && result.first() == -1 {
+		return nil, result.err
+	}
+	return result, nil
+}
@@ -0,0 +1,226 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type typedEvent struct {
+	ID   int    `csv:"id"`
+	Name string `csv:"name"`
+}
+
+func TestTyped_Process(t *testing.T) {
+	var useCases = []struct {
+		description string
+		data        interface{}
+		decoder     RecordDecoder[typedEvent]
+		failID      int
+		corruptID   int
+		expectIDs   []int
+		expectRetry string
+		corrupted   bool
+		corrupt     string
+		hasError    bool
+	}{
+		{
+			description: "single JSON record",
+			data:        []byte(`{"ID":1,"Name":"a"}`),
+			expectIDs:   []int{1},
+		},
+		{
+			description: "decoded record",
+			data:        &typedEvent{ID: 2},
+			expectIDs:   []int{2},
+		},
+		{
+			description: "batched JSON records",
+			data:        []byte("{\"ID\":1}\n{\"ID\":2}\n{\"ID\":3}"),
+			expectIDs:   []int{1, 2, 3},
+		},
+		{
+			description: "partial retry",
+			data:        []byte("{\"ID\":1}\n{\"ID\":2}\n{\"ID\":3}"),
+			failID:      2,
+			expectIDs:   []int{1, 2},
+			expectRetry: "{\"ID\":2}\n{\"ID\":3}",
+			hasError:    true,
+		},
+		{
+			description: "first record failure",
+			data:        []byte("{\"ID\":1}\n{\"ID\":2}"),
+			failID:      1,
+			expectIDs:   []int{1},
+			hasError:    true,
+		},
+		{
+			description: "corrupted record in the middle",
+			data:        []byte("{\"ID\":1}\n{\"ID\":2}\n{\"ID\":3}"),
+			corruptID:   2,
+			expectIDs:   []int{1, 2},
+			expectRetry: "{\"ID\":3}",
+			corrupt:     "{\"ID\":2}",
+			hasError:    true,
+		},
+		{
+			description: "corrupted last record",
+			data:        []byte("{\"ID\":1}\n{\"ID\":2}"),
+			corruptID:   2,
+			expectIDs:   []int{1, 2},
+			corrupt:     "{\"ID\":2}",
+			hasError:    true,
+		},
+		{
+			description: "corrupted single record",
+			data:        []byte("{\"ID\":2}"),
+			corruptID:   2,
+			expectIDs:   []int{2},
+			corrupted:   true,
+			hasError:    true,
+		},
+		{
+			description: "undecodable record",
+			data:        []byte("{\"ID\":1}\n{\"ID\":\"x\"}"),
+			expectIDs:   []int{1},
+			corrupt:     "{\"ID\":\"x\"}",
+			hasError:    true,
+		},
+		{
+			description: "undecodable records",
+			data:        []byte("{\"ID\":\"x\"}\n{\"ID\":\"y\"}"),
+			corrupted:   true,
+			hasError:    true,
+		},
+		{
+			description: "undecodable and corrupted records",
+			data:        []byte("{\"ID\":\"x\"}\n{\"ID\":2}\n{\"ID\":\"y\"}\n{\"ID\":3}"),
+			corruptID:   2,
+			expectIDs:   []int{2},
+			expectRetry: "{\"ID\":3}",
+			corrupt:     "{\"ID\":\"x\"}\n{\"ID\":2}\n{\"ID\":\"y\"}",
+			hasError:    true,
+		},
+		{
+			description: "undecodable record and first record failure",
+			data:        []byte("{\"ID\":\"x\"}\n{\"ID\":2}\n{\"ID\":3}"),
+			failID:      2,
+			expectIDs:   []int{2},
+			expectRetry: "{\"ID\":2}\n{\"ID\":3}",
+			corrupt:     "{\"ID\":\"x\"}",
+			hasError:    true,
+		},
+		{
+			description: "CSV records",
+			data:        []byte("1,a\n2,b"),
+			decoder:     mustCSVDecoder[typedEvent](",", "id", "name"),
+			expectIDs:   []int{1, 2},
+		},
+	}
+	for _, useCase := range useCases {
+		var IDs []int
+		processor := NewTyped(func(ctx context.Context, record *typedEvent, reporter Reporter) error {
+			IDs = append(IDs, record.ID)
+			if record.ID == useCase.failID {
+				return errors.New("test error")
+			}
+			if record.ID == useCase.corruptID {
+				return NewDataCorruption("test corruption")
+			}
+			return nil
+		}, useCase.decoder)
+		err := processor.Process(context.Background(), useCase.data, NewReporter())
+		assert.Equal(t, useCase.expectIDs, IDs, useCase.description)
+		if !useCase.hasError {
+			assert.Nil(t, err, useCase.description)
+			continue
+		}
+		assert.NotNil(t, err, useCase.description)
+		assert.Equal(t, useCase.corrupted, isDataCorruptionError(err), useCase.description)
+		if useCase.expectRetry != "" || useCase.corrupt != "" {
+			actual, ok := err.(*PartialRetry)
+			if !assert.True(t, ok, useCase.description) {
+				continue
+			}
+			if useCase.expectRetry == "" {
+				assert.Nil(t, actual.data, useCase.description)
+			} else {
+				assert.Equal(t, useCase.expectRetry, string(actual.data.([]byte)), useCase.description)
+			}
+			assert.Equal(t, useCase.corrupt, string(actual.corrupt), useCase.description)
+		}
+	}
+}
+
+func TestTypedBatch_Process(t *testing.T) {
+	var batches [][]int
+	processor := NewTypedBatch(func(ctx context.Context, records []*typedEvent, reporter Reporter) error {
+		var IDs []int
+		for _, record := range records {
+			IDs = append(IDs, record.ID)
+		}
+		batches = append(batches, IDs)
+		return nil
+	})
+	assert.Nil(t, processor.Process(context.Background(), []byte("{\"ID\":1}\n{\"ID\":2}"), NewReporter()))
+	assert.Nil(t, processor.Process(context.Background(), &typedEvent{ID: 3}, NewReporter()))
+	assert.True(t, isDataCorruptionError(processor.Process(context.Background(), []byte("x"), NewReporter())))
+	err := processor.Process(context.Background(), []byte("{\"ID\":4}\nx\n{\"ID\":5}"), NewReporter())
+	if actual, ok := err.(*PartialRetry); assert.True(t, ok) {
+		assert.Nil(t, actual.data)
+		assert.Equal(t, "x", string(actual.corrupt))
+	}
+	assert.Equal(t, [][]int{{1, 2}, {3}, {4, 5}}, batches)
+}
+
+func TestService_Do_Typed(t *testing.T) {
+	var mux sync.Mutex
+	var names []string
+	processor := NewTyped(func(ctx context.Context, record *typedEvent, reporter Reporter) error {
+		mux.Lock()
+		defer mux.Unlock()
+		names = append(names, record.Name)
+		return nil
+	})
+	cfg := &Config{Concurrency: 1, MaxExecTimeMs: 2000, MaxRetries: 3}
+	srv := New(cfg, afs.New(), processor, NewReporter)
+	request := NewRequest(strings.NewReader("id,name\n1,a\n2,b"), nil, "mem://localhost/data/events.csv")
+	request.SourceType = CSVWithHeader
+	response := srv.Do(context.Background(), request).BaseResponse()
+	assert.EqualValues(t, 2, response.Processed)
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	fs := afs.New()
+	corrupting := NewTyped(func(ctx context.Context, record *typedEvent, reporter Reporter) error {
+		if record.ID == 2 {
+			return NewDataCorruption("invalid event")
+		}
+		return nil
+	}, mustCSVDecoder[typedEvent](",", "id", "name"))
+	cfg = &Config{Concurrency: 1, MaxExecTimeMs: 2000, MaxRetries: 3, BatchSize: 3,
+		RetryURL:      "mem://localhost/typed/retry",
+		CorruptionURL: "mem://localhost/typed/corruption",
+	}
+	srv = New(cfg, fs, corrupting, NewReporter)
+	request = NewRequest(strings.NewReader("1,a\n2,b\n3,c\nx,d\n5,e"), nil, "mem://localhost/data/typed/events.csv")
+	response = srv.Do(context.Background(), request).BaseResponse()
+	assert.EqualValues(t, 2, response.CorruptionErrors)
+	corrupted, err := fs.DownloadWithURL(context.Background(), response.CorruptionURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "2,b\nx,d", string(corrupted))
+	retried, err := fs.DownloadWithURL(context.Background(), response.RetryURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "3,c", string(retried))
+}
+
+func mustCSVDecoder[T any](delimiter string, columns ...string) RecordDecoder[T] {
+	decoder, err := CSVDecoder[T](delimiter, columns...)
+	if err != nil {
+		panic(err)
+	}
+	return decoder
+}
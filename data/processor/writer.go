@@ -3,22 +3,39 @@ package processor
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"github.com/viant/afs"
 	"github.com/viant/afs/file"
 	"github.com/viant/cloudless/ioutil"
 	"io"
 	"strings"
 	"sync"
+	"time"
 )
 
+type retryKeyType string
+
+// retryKey represents context key of the retry destination available to the Processor in Post
+const retryKey = retryKeyType("retry")
+
+// retryDestination represents retry destination, data not finalized in Post before deadline is written there
+type retryDestination struct {
+	writer   *Writer
+	deadline time.Time
+}
+
 // Writer represents text data writer
 type Writer struct {
-	writer  io.WriteCloser
-	mutex   sync.Mutex
-	codec   string
-	counter int32
-	url     string
-	fs      afs.Service
+	writer     io.WriteCloser
+	mutex      sync.Mutex
+	codec      string
+	counter    int32
+	url        string
+	fs         afs.Service
+	envelope   *Envelope
+	failed     *Writer //destination for the enveloped records exceeding max retries
+	maxRetries int
+	header     func() []byte //optional source header written as the first line
 }
 
 func (w *Writer) Write(ctx context.Context, data []byte) (err error) {
@@ -37,6 +54,13 @@ func (w *Writer) Write(ctx context.Context, data []byte) (err error) {
 		} else {
 			w.writer = writer
 		}
+		if w.header != nil {
+			if header := w.header(); len(header) > 0 {
+				if _, err = w.writer.Write(append(header, '\n')); err != nil {
+					return err
+				}
+			}
+		}
 	} else {
 		_, err = w.writer.Write([]byte{'\n'})
 		if err != nil {
@@ -48,12 +72,27 @@ func (w *Writer) Write(ctx context.Context, data []byte) (err error) {
 	return err
 }
 
-// Close closes the writer if there are any writes
+// writeRecord writes failed record, record is wrapped with an envelope if enabled
+func (w *Writer) writeRecord(ctx context.Context, data []byte, rec *record, cause error) error {
+	if w == nil {
+		return nil
+	}
+	if w.envelope != nil {
+		return w.writeEnvelope(ctx, data, rec, cause)
+	}
+	return w.Write(ctx, data)
+}
+
+// Close closes the writer if there are any writes, failed records writer is closed too
 func (w *Writer) Close() error {
+	var err error
+	if w.failed != nil {
+		err = w.failed.Close()
+	}
 	if w.counter == 0 {
-		return nil
+		return err
 	}
-	return w.writer.Close()
+	return errors.Join(err, w.writer.Close())
 }
 
 // NewWriter creates a writer
@@ -29,6 +29,7 @@ require (
 	github.com/viant/toolbox v0.36.0
 	golang.org/x/net v0.24.0
 	golang.org/x/oauth2 v0.19.0
+	golang.org/x/time v0.5.0
 	google.golang.org/api v0.174.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -94,7 +95,6 @@ require (
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
 	google.golang.org/genproto v0.0.0-20240227224415-6ceb2ff114de // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240314234333-6e1732d8331c // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240415180920-8c6c420018be // indirect